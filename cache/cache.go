@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend stores encoded values. A ttl of zero means no expiry.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type Config struct {
+	// Backend defaults to an in-memory LRU holding MaxEntries values.
+	Backend    Backend
+	MaxEntries int
+	// DefaultTTL is used when a TTL of zero is passed. Zero means values do
+	// not expire.
+	DefaultTTL time.Duration
+	// Prefix namespaces the keys of this cache, e.g. in a shared Redis.
+	Prefix string
+}
+
+// Cache stores JSON encoded values in a backend.
+type Cache struct {
+	config Config
+	group  singleflight.Group
+}
+
+func New(cfg Config) *Cache {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	if cfg.Backend == nil {
+		cfg.Backend = NewMemoryBackend(cfg.MaxEntries)
+	}
+	return &Cache{config: cfg}
+}
+
+// Get decodes the value of key into out and reports whether it was found.
+func (c *Cache) Get(ctx context.Context, key string, out any) (bool, error) {
+	data, ok, err := c.config.Backend.Get(ctx, c.config.Prefix+key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get cache key %s: %w", key, err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("failed to decode cache key %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores value under key for ttl, or for DefaultTTL when ttl is zero.
+func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache key %s: %w", key, err)
+	}
+	if ttl == 0 {
+		ttl = c.config.DefaultTTL
+	}
+	if err := c.config.Backend.Set(ctx, c.config.Prefix+key, data, ttl); err != nil {
+		return fmt.Errorf("failed to set cache key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := c.config.Backend.Delete(ctx, c.config.Prefix+key); err != nil {
+		return fmt.Errorf("failed to delete cache key %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetOrLoad returns the cached value of key, calling load and caching its
+// result on a miss. Concurrent misses for the same key in this process share
+// a single load call. Values are not cached when load fails, and a failing
+// backend is bypassed rather than failing the call.
+func GetOrLoad[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	if ok, err := c.Get(ctx, key, &value); err == nil && ok {
+		return value, nil
+	}
+
+	result, err, _ := c.group.Do(key, func() (any, error) {
+		loaded, err := load(ctx)
+		if err != nil {
+			return loaded, err
+		}
+		// A failed write only costs a future miss.
+		_ = c.Set(ctx, key, loaded, ttl)
+		return loaded, nil
+	})
+	if err != nil {
+		return value, err
+	}
+	// A nil interface value loaded for an interface T does not assert.
+	loaded, _ := result.(T)
+	return loaded, nil
+}
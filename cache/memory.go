@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+type memoryBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// order holds the entries from most to least recently used.
+	order *list.List
+}
+
+// NewMemoryBackend returns an in-process LRU backend that evicts the least
+// recently used value once it holds maxEntries values.
+func NewMemoryBackend(maxEntries int) Backend {
+	return &memoryBackend{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+func (b *memoryBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	element, ok := b.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		b.removeLocked(element)
+		return nil, false, nil
+	}
+
+	b.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (b *memoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if element, ok := b.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		b.order.MoveToFront(element)
+		return nil
+	}
+
+	b.entries[key] = b.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for b.maxEntries > 0 && b.order.Len() > b.maxEntries {
+		b.removeLocked(b.order.Back())
+	}
+	return nil
+}
+
+func (b *memoryBackend) Delete(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if element, ok := b.entries[key]; ok {
+		b.removeLocked(element)
+	}
+	return nil
+}
+
+func (b *memoryBackend) removeLocked(element *list.Element) {
+	b.order.Remove(element)
+	delete(b.entries, element.Value.(*memoryEntry).key)
+}
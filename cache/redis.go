@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delightmichael1/go-libs/utils"
+)
+
+// The scripts avoid nil replies, which some clients report as errors.
+const (
+	redisGetScript = `
+local value = redis.call('GET', KEYS[1])
+if value then
+  return {value}
+end
+return {}
+`
+	redisSetScript = `
+if tonumber(ARGV[2]) > 0 then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+  redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`
+	redisDeleteScript = `return redis.call('DEL', KEYS[1])`
+)
+
+type redisBackend struct {
+	client utils.RedisEvaler
+}
+
+// NewRedisBackend returns a backend storing values in Redis. It takes the
+// same client adapter as utils.NewRedisRateLimitStore, so this module does
+// not depend on a Redis client.
+func NewRedisBackend(client utils.RedisEvaler) Backend {
+	return &redisBackend{client: client}
+}
+
+func (b *redisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	result, err := b.client.Eval(ctx, redisGetScript, []string{key})
+	if err != nil {
+		return nil, false, err
+	}
+	reply, ok := result.([]any)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected cache reply: %v", result)
+	}
+	if len(reply) == 0 {
+		return nil, false, nil
+	}
+
+	switch value := reply[0].(type) {
+	case string:
+		return []byte(value), true, nil
+	case []byte:
+		return value, true, nil
+	}
+	return nil, false, fmt.Errorf("unexpected cache reply: %v", result)
+}
+
+func (b *redisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// PX takes whole milliseconds; rounding down would turn a short ttl
+	// into no expiry.
+	millis := ttl.Milliseconds()
+	if ttl > 0 && ttl%time.Millisecond != 0 {
+		millis++
+	}
+	_, err := b.client.Eval(ctx, redisSetScript, []string{key}, value, millis)
+	return err
+}
+
+func (b *redisBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.Eval(ctx, redisDeleteScript, []string{key})
+	return err
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/delightmichael1/go-libs/mailer"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings of every module. A nil section is not
+// initialized by InitAll; Load only creates the sections that appear in the
+// YAML file or have at least one environment variable set.
+type Config struct {
+	Mongo         *MongoConfig         `yaml:"mongo"`
+	Files         *FilesConfig         `yaml:"files"`
+	Mailer        *MailerConfig        `yaml:"mailer"`
+	Notifications *NotificationsConfig `yaml:"notifications"`
+}
+
+type MongoConfig struct {
+	URI      string `yaml:"uri" env:"MONGODB_URI"`
+	Database string `yaml:"database" env:"MONGODB_DATABASE"`
+}
+
+type FilesConfig struct {
+	Bucket          string        `yaml:"bucket" env:"STORAGE_BUCKET"`
+	CredentialsFile string        `yaml:"credentialsFile" env:"STORAGE_CREDENTIALS_FILE"`
+	ProjectID       string        `yaml:"projectId" env:"STORAGE_PROJECT_ID"`
+	Endpoint        string        `yaml:"endpoint" env:"STORAGE_ENDPOINT"`
+	EmulatorHost    string        `yaml:"emulatorHost" env:"STORAGE_EMULATOR_HOST"`
+	Timeout         time.Duration `yaml:"timeout" env:"STORAGE_TIMEOUT"`
+}
+
+type MailerConfig struct {
+	Provider               string         `yaml:"provider" env:"MAILER_PROVIDER" default:"smtp"`
+	Failover               []string       `yaml:"failover" env:"MAILER_FAILOVER"`
+	EmailAccount           string         `yaml:"emailAccount" env:"EMAIL_ACCOUNT"`
+	EmailPassword          string         `yaml:"emailPassword" env:"EMAIL_PASSWORD"`
+	SMTPHost               string         `yaml:"smtpHost" env:"SMTP_HOST"`
+	SMTPPort               int            `yaml:"smtpPort" env:"SMTP_PORT" default:"587"`
+	SMTPSecurity           string         `yaml:"smtpSecurity" env:"SMTP_SECURITY"`
+	SMTPCACertFile         string         `yaml:"smtpCaCertFile" env:"SMTP_CA_CERT_FILE"`
+	SMTPInsecureSkipVerify bool           `yaml:"smtpInsecureSkipVerify" env:"SMTP_INSECURE_SKIP_VERIFY"`
+	LocalName              string         `yaml:"localName" env:"SMTP_LOCAL_NAME"`
+	IdleTimeout            time.Duration  `yaml:"idleTimeout" env:"SMTP_IDLE_TIMEOUT"`
+	AllowedSenders         []string       `yaml:"allowedSenders" env:"MAILER_ALLOWED_SENDERS"`
+	Bcc                    []string       `yaml:"bcc" env:"MAILER_BCC"`
+	SendGrid               SendGridConfig `yaml:"sendgrid"`
+	SES                    SESConfig      `yaml:"ses"`
+	Mailgun                MailgunConfig  `yaml:"mailgun"`
+}
+
+type SendGridConfig struct {
+	APIKey  string `yaml:"apiKey" env:"SENDGRID_API_KEY"`
+	BaseURL string `yaml:"baseUrl" env:"SENDGRID_BASE_URL"`
+}
+
+type SESConfig struct {
+	Region          string `yaml:"region" env:"SES_REGION"`
+	AccessKeyID     string `yaml:"accessKeyId" env:"SES_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" env:"SES_SECRET_ACCESS_KEY"`
+	SessionToken    string `yaml:"sessionToken" env:"SES_SESSION_TOKEN"`
+	Endpoint        string `yaml:"endpoint" env:"SES_ENDPOINT"`
+}
+
+type MailgunConfig struct {
+	Domain  string `yaml:"domain" env:"MAILGUN_DOMAIN"`
+	APIKey  string `yaml:"apiKey" env:"MAILGUN_API_KEY"`
+	BaseURL string `yaml:"baseUrl" env:"MAILGUN_BASE_URL"`
+}
+
+type NotificationsConfig struct {
+	CredentialsFile string         `yaml:"credentialsFile" env:"FIREBASE_CREDENTIALS_FILE"`
+	CredentialsJSON string         `yaml:"credentialsJson" env:"FIREBASE_CREDENTIALS_JSON"`
+	ProjectID       string         `yaml:"projectId" env:"FIREBASE_PROJECT_ID"`
+	InboxCollection string         `yaml:"inboxCollection" env:"NOTIFICATIONS_INBOX_COLLECTION"`
+	InboxPageSize   int            `yaml:"inboxPageSize" env:"NOTIFICATIONS_INBOX_PAGE_SIZE"`
+	Retry           RetryConfig    `yaml:"retry"`
+	WebPush         *WebPushConfig `yaml:"webPush"`
+	Twilio          *TwilioConfig  `yaml:"twilio"`
+	OnInvalidToken  func(string)   `yaml:"-"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts" env:"NOTIFICATIONS_RETRY_MAX_ATTEMPTS"`
+	InitialBackoff time.Duration `yaml:"initialBackoff" env:"NOTIFICATIONS_RETRY_INITIAL_BACKOFF"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff" env:"NOTIFICATIONS_RETRY_MAX_BACKOFF"`
+}
+
+type WebPushConfig struct {
+	PublicKey  string `yaml:"publicKey" env:"WEBPUSH_PUBLIC_KEY"`
+	PrivateKey string `yaml:"privateKey" env:"WEBPUSH_PRIVATE_KEY"`
+	Subject    string `yaml:"subject" env:"WEBPUSH_SUBJECT"`
+}
+
+type TwilioConfig struct {
+	AccountSID          string `yaml:"accountSid" env:"TWILIO_ACCOUNT_SID"`
+	AuthToken           string `yaml:"authToken" env:"TWILIO_AUTH_TOKEN"`
+	From                string `yaml:"from" env:"TWILIO_FROM"`
+	MessagingServiceSID string `yaml:"messagingServiceSid" env:"TWILIO_MESSAGING_SERVICE_SID"`
+	StatusCallbackURL   string `yaml:"statusCallbackUrl" env:"TWILIO_STATUS_CALLBACK_URL"`
+}
+
+type LoadOptions struct {
+	// EnvFiles are .env files loaded into the environment first. Missing
+	// files are skipped; variables already set are not overridden.
+	EnvFiles []string
+	// YAMLFile is an optional YAML file. ${VAR} references in it are
+	// expanded from the environment.
+	YAMLFile string
+	// EnvPrefix is prepended to every variable name, e.g. "APP_" to read
+	// APP_MONGODB_URI.
+	EnvPrefix string
+}
+
+// Load builds a Config from .env files, a YAML file and environment
+// variables, in increasing order of precedence, applies defaults and
+// validates the result.
+func Load(opts LoadOptions) (*Config, error) {
+	for _, path := range opts.EnvFiles {
+		if err := LoadEnvFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{}
+	if opts.YAMLFile != "" {
+		content, err := os.ReadFile(opts.YAMLFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(expandEnvReferences(content), cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", opts.YAMLFile, err)
+		}
+	}
+
+	if _, err := applyEnv(reflect.ValueOf(cfg).Elem(), opts.EnvPrefix); err != nil {
+		return nil, err
+	}
+	if err := applyDefaults(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envReference matches ${VAR}. Bare $VAR is left alone so values such as
+// passwords may contain dollar signs.
+var envReference = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+func expandEnvReferences(content []byte) []byte {
+	return envReference.ReplaceAllFunc(content, func(ref []byte) []byte {
+		return []byte(os.Getenv(string(ref[2 : len(ref)-1])))
+	})
+}
+
+// Validate checks that every configured section has its required settings
+// and reports all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Mongo != nil {
+		if c.Mongo.URI == "" {
+			errs = append(errs, fmt.Errorf("mongo: uri is required"))
+		}
+		if c.Mongo.Database == "" {
+			errs = append(errs, fmt.Errorf("mongo: database is required"))
+		}
+	}
+
+	if c.Files != nil {
+		if c.Files.Bucket == "" {
+			errs = append(errs, fmt.Errorf("files: bucket is required"))
+		}
+	}
+
+	if c.Mailer != nil {
+		if c.Mailer.EmailAccount == "" {
+			errs = append(errs, fmt.Errorf("mailer: emailAccount is required"))
+		}
+		for _, provider := range append([]string{c.Mailer.Provider}, c.Mailer.Failover...) {
+			if err := c.Mailer.validateProvider(provider); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if c.Notifications != nil {
+		n := c.Notifications
+		if n.ProjectID == "" && n.WebPush == nil && n.Twilio == nil && n.InboxCollection == "" {
+			errs = append(errs, fmt.Errorf("notifications: projectId, webPush, twilio or inboxCollection is required"))
+		}
+		if n.WebPush != nil && (n.WebPush.PublicKey == "" || n.WebPush.PrivateKey == "") {
+			errs = append(errs, fmt.Errorf("notifications: webPush publicKey and privateKey are required"))
+		}
+		if n.Twilio != nil && (n.Twilio.AccountSID == "" || n.Twilio.AuthToken == "") {
+			errs = append(errs, fmt.Errorf("notifications: twilio accountSid and authToken are required"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *MailerConfig) validateProvider(provider string) error {
+	switch provider {
+	case mailer.ProviderSMTP:
+		if m.SMTPHost == "" {
+			return fmt.Errorf("mailer: smtpHost is required for the smtp provider")
+		}
+	case mailer.ProviderSendGrid:
+		if m.SendGrid.APIKey == "" {
+			return fmt.Errorf("mailer: sendgrid apiKey is required")
+		}
+	case mailer.ProviderSES:
+		if m.SES.Region == "" || m.SES.AccessKeyID == "" || m.SES.SecretAccessKey == "" {
+			return fmt.Errorf("mailer: ses region, accessKeyId and secretAccessKey are required")
+		}
+	case mailer.ProviderMailgun:
+		if m.Mailgun.Domain == "" || m.Mailgun.APIKey == "" {
+			return fmt.Errorf("mailer: mailgun domain and apiKey are required")
+		}
+	case mailer.ProviderSandbox:
+	default:
+		return fmt.Errorf("mailer: unknown provider %q", provider)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadEnvFile sets the variables of a .env file that are not already set in
+// the environment. Lines have the form KEY=VALUE, optionally prefixed with
+// "export"; blank lines and lines starting with # are ignored, and values
+// may be quoted.
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value: %w", err)
+		}
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return value[1 : end+1], nil
+	}
+	// Unquoted values may carry a trailing comment.
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+// applyEnv sets the fields of v tagged with env from the environment. Nil
+// struct pointers are only allocated when one of their variables is set. It
+// reports whether any variable was applied.
+func applyEnv(v reflect.Value, prefix string) (bool, error) {
+	applied := false
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		fieldValue := v.Field(i)
+
+		switch {
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			target := fieldValue
+			if target.IsNil() {
+				target = reflect.New(field.Type.Elem())
+			}
+			ok, err := applyEnv(target.Elem(), prefix)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				fieldValue.Set(target)
+				applied = true
+			}
+
+		case field.Type.Kind() == reflect.Struct:
+			ok, err := applyEnv(fieldValue, prefix)
+			if err != nil {
+				return false, err
+			}
+			applied = applied || ok
+
+		default:
+			name := field.Tag.Get("env")
+			if name == "" {
+				continue
+			}
+			raw, ok := os.LookupEnv(prefix + name)
+			if !ok {
+				continue
+			}
+			if err := setField(fieldValue, raw); err != nil {
+				return false, fmt.Errorf("invalid value for %s: %w", prefix+name, err)
+			}
+			applied = true
+		}
+	}
+	return applied, nil
+}
+
+// applyDefaults sets zero fields tagged with default in every non-nil section.
+func applyDefaults(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		fieldValue := v.Field(i)
+
+		switch {
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			if !fieldValue.IsNil() {
+				if err := applyDefaults(fieldValue.Elem()); err != nil {
+					return err
+				}
+			}
+		case field.Type.Kind() == reflect.Struct:
+			if err := applyDefaults(fieldValue); err != nil {
+				return err
+			}
+		default:
+			if def := field.Tag.Get("default"); def != "" && fieldValue.IsZero() {
+				if err := setField(fieldValue, def); err != nil {
+					return fmt.Errorf("invalid default for %s: %w", field.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
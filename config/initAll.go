@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delightmichael1/go-libs/mailer"
+	"github.com/delightmichael1/go-libs/notifications"
+	"github.com/delightmichael1/go-libs/storage"
+)
+
+// InitAll initializes every module that has a section in cfg: MongoDB first,
+// since other modules may store data in it, then files, the mailer and
+// notifications. It stops at the first failure.
+func InitAll(ctx context.Context, cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Mongo != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := storage.Initialize(cfg.Mongo.StorageConfig()); err != nil {
+			return fmt.Errorf("failed to initialize MongoDB: %w", err)
+		}
+	}
+
+	if cfg.Files != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := storage.InitializeFiles(cfg.Files.StorageConfig()); err != nil {
+			return fmt.Errorf("failed to initialize files: %w", err)
+		}
+	}
+
+	if cfg.Mailer != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := mailer.Initialize(cfg.Mailer.MailerConfig()); err != nil {
+			return fmt.Errorf("failed to initialize mailer: %w", err)
+		}
+	}
+
+	if cfg.Notifications != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := notifications.Initialize(cfg.Notifications.NotificationsConfig()); err != nil {
+			return fmt.Errorf("failed to initialize notifications: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// StorageConfig converts the section for storage.Initialize.
+func (c *MongoConfig) StorageConfig() storage.Config {
+	return storage.Config{URI: c.URI, DatabaseName: c.Database}
+}
+
+// StorageConfig converts the section for storage.InitializeFiles.
+func (c *FilesConfig) StorageConfig() storage.FilesConfig {
+	return storage.FilesConfig{
+		BucketName:      c.Bucket,
+		CredentialsFile: c.CredentialsFile,
+		ProjectID:       c.ProjectID,
+		Endpoint:        c.Endpoint,
+		EmulatorHost:    c.EmulatorHost,
+		Timeout:         c.Timeout,
+	}
+}
+
+// MailerConfig converts the section for mailer.Initialize. Settings that
+// cannot be expressed in a file, such as a custom Transport, can be added to
+// the result before initializing the mailer directly.
+func (c *MailerConfig) MailerConfig() mailer.Config {
+	return mailer.Config{
+		SMTPHost:               c.SMTPHost,
+		SMTPPort:               c.SMTPPort,
+		EmailAccount:           c.EmailAccount,
+		EmailPassword:          c.EmailPassword,
+		SMTPSecurity:           c.SMTPSecurity,
+		SMTPCACertFile:         c.SMTPCACertFile,
+		SMTPInsecureSkipVerify: c.SMTPInsecureSkipVerify,
+		LocalName:              c.LocalName,
+		AllowedSenders:         c.AllowedSenders,
+		Bcc:                    c.Bcc,
+		IdleTimeout:            c.IdleTimeout,
+		Provider:               c.Provider,
+		Failover:               c.Failover,
+		SendGrid:               mailer.SendGridConfig(c.SendGrid),
+		SES:                    mailer.SESConfig(c.SES),
+		Mailgun:                mailer.MailgunConfig(c.Mailgun),
+	}
+}
+
+// NotificationsConfig converts the section for notifications.Initialize.
+func (c *NotificationsConfig) NotificationsConfig() notifications.Config {
+	cfg := notifications.Config{
+		CredentialsFile: c.CredentialsFile,
+		ProjectID:       c.ProjectID,
+		OnInvalidToken:  c.OnInvalidToken,
+		InboxCollection: c.InboxCollection,
+		InboxPageSize:   c.InboxPageSize,
+		Retry:           notifications.RetryConfig(c.Retry),
+	}
+	if c.CredentialsJSON != "" {
+		cfg.CredentialsJSON = []byte(c.CredentialsJSON)
+	}
+	if c.WebPush != nil {
+		webPush := notifications.WebPushConfig(*c.WebPush)
+		cfg.WebPush = &webPush
+	}
+	if c.Twilio != nil {
+		cfg.Twilio = &notifications.TwilioConfig{
+			AccountSID:          c.Twilio.AccountSID,
+			AuthToken:           c.Twilio.AuthToken,
+			From:                c.Twilio.From,
+			MessagingServiceSID: c.Twilio.MessagingServiceSID,
+			StatusCallbackURL:   c.Twilio.StatusCallbackURL,
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/storage"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+// Job is a unit of work stored in the queue. Payload holds the BSON
+// document passed to Enqueue; handlers read it with Bind.
+type Job struct {
+	ID          string    `bson:"_id" json:"id"`
+	Name        string    `bson:"name" json:"name"`
+	Payload     bson.Raw  `bson:"payload,omitempty" json:"-"`
+	Status      string    `bson:"status" json:"status"`
+	Attempts    int       `bson:"attempts" json:"attempts"`
+	MaxAttempts int       `bson:"maxAttempts" json:"maxAttempts"`
+	LastError   string    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	RunAt       time.Time `bson:"runAt" json:"runAt"`
+	LockedUntil time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// Bind decodes the job's payload into out.
+func (j *Job) Bind(out any) error {
+	if len(j.Payload) == 0 {
+		return fmt.Errorf("job %s has no payload", j.ID)
+	}
+	return bson.Unmarshal(j.Payload, out)
+}
+
+// Handler processes a job. Returning an error retries the job with backoff
+// until its attempts run out; wrap the error with Permanent to give up
+// immediately. The context is cancelled when the visibility timeout expires
+// or the workers are stopped.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, so the job is moved to the
+// dead letters right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+var (
+	handlers   = map[string]Handler{}
+	handlersMu sync.RWMutex
+)
+
+// Register sets the handler for jobs named name. Workers only claim jobs
+// that have a handler, so services with different handlers can share a
+// collection.
+func Register(name string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[name] = handler
+}
+
+func registeredNames() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	return names
+}
+
+func handlerFor(name string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[name]
+	return handler, ok
+}
+
+type EnqueueOptions struct {
+	// Delay postpones the first attempt.
+	Delay time.Duration
+	// MaxAttempts overrides Config.MaxAttempts for this job.
+	MaxAttempts int
+}
+
+// Enqueue stores a job for the handler registered as name and returns its
+// ID. payload must marshal to a BSON document, e.g. a struct or map; it may
+// be nil.
+func Enqueue(ctx context.Context, name string, payload any) (string, error) {
+	return EnqueueWith(ctx, name, payload, EnqueueOptions{})
+}
+
+// EnqueueIn is Enqueue with the first attempt postponed by delay.
+func EnqueueIn(ctx context.Context, name string, payload any, delay time.Duration) (string, error) {
+	return EnqueueWith(ctx, name, payload, EnqueueOptions{Delay: delay})
+}
+
+// EnqueueWith is Enqueue with options.
+func EnqueueWith(ctx context.Context, name string, payload any, opts EnqueueOptions) (string, error) {
+	if !isStarted() {
+		return "", fmt.Errorf("jobs not started. Call Start() first")
+	}
+	if name == "" {
+		return "", fmt.Errorf("job name cannot be empty")
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = jobsConfig.MaxAttempts
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          uuid.NewString(),
+		Name:        name,
+		Status:      StatusPending,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       now.Add(opts.Delay),
+		CreatedAt:   now,
+	}
+	if payload != nil {
+		raw, err := bson.Marshal(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode payload of job %s: %w", name, err)
+		}
+		job.Payload = raw
+	}
+
+	if _, err := storage.InsertData(ctx, jobsConfig.Collection, job); err != nil {
+		return "", fmt.Errorf("failed to enqueue job %s: %w", name, err)
+	}
+
+	if opts.Delay <= 0 {
+		select {
+		case jobsWake <- struct{}{}:
+		default:
+		}
+	}
+	return job.ID, nil
+}
+
+// Cancel removes a job that has not started running yet.
+func Cancel(ctx context.Context, id string) error {
+	if !isStarted() {
+		return fmt.Errorf("jobs not started. Call Start() first")
+	}
+
+	result, err := storage.DeleteOne(ctx, jobsConfig.Collection, bson.M{"_id": id, "status": StatusPending})
+	if err != nil {
+		return fmt.Errorf("failed to cancel job %s: %w", id, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("job %s not found or already running", id)
+	}
+	return nil
+}
+
+// DeadLetters returns up to limit jobs that failed permanently or ran out of
+// attempts, most recent first.
+func DeadLetters(ctx context.Context, limit int) ([]Job, error) {
+	if !isStarted() {
+		return nil, fmt.Errorf("jobs not started. Call Start() first")
+	}
+	collection := storage.GetCollectionRef(ctx, jobsConfig.Collection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", jobsConfig.Collection)
+	}
+
+	opts := options.Find().SetSort(bson.M{"runAt": -1})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := collection.Find(ctx, bson.M{"status": StatusDead}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead jobs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []Job{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to decode dead jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// RetryDead moves a dead job back to the queue with fresh attempts.
+func RetryDead(ctx context.Context, id string) error {
+	if !isStarted() {
+		return fmt.Errorf("jobs not started. Call Start() first")
+	}
+
+	result, err := storage.UpdateOne(ctx, jobsConfig.Collection, bson.M{"_id": id, "status": StatusDead}, bson.M{
+		"status":   StatusPending,
+		"attempts": 0,
+		"runAt":    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retry job %s: %w", id, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("dead job %s not found", id)
+	}
+
+	select {
+	case jobsWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,275 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Config struct {
+	// Collection defaults to "jobs". The storage package must be initialized
+	// before the workers are started.
+	Collection string
+	// Workers defaults to 4.
+	Workers      int
+	PollInterval time.Duration
+	// VisibilityTimeout is how long a worker may hold a job before it is
+	// handed out again, e.g. after a crash. Defaults to 5 minutes.
+	VisibilityTimeout time.Duration
+	// MaxAttempts includes the first attempt. Defaults to 5.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var (
+	jobsConfig Config
+	jobsMu     sync.Mutex
+	jobsWake   chan struct{}
+	jobsCancel context.CancelFunc
+	jobsDone   sync.WaitGroup
+)
+
+// Start starts the worker pool. Several processes may share a collection;
+// each job is run by one worker at a time.
+func Start(cfg Config) error {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	if jobsCancel != nil {
+		return fmt.Errorf("jobs already started")
+	}
+
+	if cfg.Collection == "" {
+		cfg.Collection = "jobs"
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 5 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+
+	collection := storage.GetCollectionRef(context.Background(), cfg.Collection)
+	if collection == nil {
+		return fmt.Errorf("failed to get collection %s", cfg.Collection)
+	}
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "name", Value: 1}, {Key: "runAt", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create jobs index: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jobsConfig = cfg
+	jobsWake = make(chan struct{}, cfg.Workers)
+	jobsCancel = cancel
+
+	for i := 0; i < cfg.Workers; i++ {
+		jobsDone.Add(1)
+		go runWorker(ctx)
+	}
+
+	logging.Info("Job workers started", "workers", cfg.Workers)
+	return nil
+}
+
+// Stop stops the workers and waits for running jobs to finish. Their
+// contexts are cancelled, so handlers should return promptly.
+func Stop() {
+	jobsMu.Lock()
+	cancel := jobsCancel
+	jobsCancel = nil
+	jobsMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	jobsDone.Wait()
+}
+
+func isStarted() bool {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return jobsCancel != nil
+}
+
+func runWorker(ctx context.Context) {
+	defer jobsDone.Done()
+
+	ticker := time.NewTicker(jobsConfig.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for processNextJob(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-jobsWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNextJob runs one due job and reports whether there was one.
+func processNextJob(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := claimJob(ctx)
+	if err != nil {
+		logging.Error("Failed to claim job", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	runErr := runJob(ctx, job)
+
+	// The worker context may be cancelled by Stop; the outcome is still
+	// recorded. The filter skips jobs whose lease expired and that another
+	// worker has claimed since.
+	claimed := bson.M{"_id": job.ID, "status": StatusRunning, "attempts": job.Attempts}
+	if runErr == nil {
+		if _, err := storage.DeleteOne(context.Background(), jobsConfig.Collection, claimed); err != nil {
+			logging.Error("Failed to remove finished job", "id", job.ID, "error", err)
+		}
+		return true
+	}
+
+	update := bson.M{"lastError": runErr.Error()}
+	if isPermanent(runErr) || job.Attempts >= job.MaxAttempts {
+		update["status"] = StatusDead
+		logging.Error("Job moved to dead letters", "id", job.ID, "name", job.Name, "attempts", job.Attempts, "error", runErr)
+	} else {
+		update["status"] = StatusPending
+		update["runAt"] = time.Now().Add(backoff(job.Attempts))
+		logging.Warn("Job failed, retrying", "id", job.ID, "name", job.Name, "attempts", job.Attempts, "error", runErr)
+	}
+
+	if _, err := storage.UpdateOne(context.Background(), jobsConfig.Collection, claimed, update); err != nil {
+		logging.Error("Failed to update job", "id", job.ID, "error", err)
+	}
+	return true
+}
+
+// runJob calls the job's handler, turning panics into errors.
+func runJob(ctx context.Context, job *Job) (err error) {
+	handler, ok := handlerFor(job.Name)
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for job %s", job.Name))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jobsConfig.VisibilityTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	err = handler(ctx, job)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("job exceeded visibility timeout: %w", err)
+	}
+	return err
+}
+
+// claimJob leases the next due job that has a registered handler, or a
+// running job whose visibility timeout expired.
+func claimJob(ctx context.Context) (*Job, error) {
+	names := registeredNames()
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	collection := storage.GetCollectionRef(ctx, jobsConfig.Collection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", jobsConfig.Collection)
+	}
+
+	now := time.Now()
+
+	// A job whose lease expired on its last attempt most likely crashed the
+	// process running it, so it is not run again.
+	exhausted := bson.M{
+		"name":        bson.M{"$in": names},
+		"status":      StatusRunning,
+		"lockedUntil": bson.M{"$lte": now},
+		"$expr":       bson.M{"$gte": bson.A{"$attempts", "$maxAttempts"}},
+	}
+	dead := bson.M{"$set": bson.M{"status": StatusDead, "lastError": "job lease expired on its last attempt"}}
+	result, err := collection.UpdateMany(ctx, exhausted, dead)
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount > 0 {
+		logging.Error("Jobs moved to dead letters after their lease expired", "count", result.ModifiedCount)
+	}
+
+	filter := bson.M{
+		"name": bson.M{"$in": names},
+		"$or": []bson.M{
+			{"status": StatusPending, "runAt": bson.M{"$lte": now}},
+			{
+				"status":      StatusRunning,
+				"lockedUntil": bson.M{"$lte": now},
+				"$expr":       bson.M{"$lt": bson.A{"$attempts", "$maxAttempts"}},
+			},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": StatusRunning, "lockedUntil": now.Add(jobsConfig.VisibilityTimeout)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"runAt": 1}).
+		SetReturnDocument(options.After)
+
+	var job Job
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func backoff(attempts int) time.Duration {
+	delay := jobsConfig.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= jobsConfig.MaxBackoff {
+			return jobsConfig.MaxBackoff
+		}
+	}
+	return delay
+}
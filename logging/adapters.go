@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// FromSlog adapts a *slog.Logger, e.g. one with a custom handler.
+func FromSlog(l *slog.Logger) Logger {
+	return slogLogger{logger: l}
+}
+
+func (l slogLogger) Log(ctx context.Context, level Level, msg string, args ...any) {
+	l.logger.Log(ctx, slog.Level(level), msg, args...)
+}
+
+// ZapLogger is the part of *zap.SugaredLogger used by FromZap, so this
+// module does not depend on zap:
+//
+//	logging.SetLogger(logging.FromZap(zapLogger.Sugar()))
+type ZapLogger interface {
+	Debugw(msg string, keysAndValues ...any)
+	Infow(msg string, keysAndValues ...any)
+	Warnw(msg string, keysAndValues ...any)
+	Errorw(msg string, keysAndValues ...any)
+}
+
+type zapLogger struct {
+	logger ZapLogger
+}
+
+// FromZap adapts a zap SugaredLogger.
+func FromZap(l ZapLogger) Logger {
+	return zapLogger{logger: l}
+}
+
+func (l zapLogger) Log(_ context.Context, level Level, msg string, args ...any) {
+	switch {
+	case level >= LevelError:
+		l.logger.Errorw(msg, args...)
+	case level >= LevelWarn:
+		l.logger.Warnw(msg, args...)
+	case level >= LevelInfo:
+		l.logger.Infow(msg, args...)
+	default:
+		l.logger.Debugw(msg, args...)
+	}
+}
+
+// Discard is a Logger that drops every record.
+var Discard Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(context.Context, Level, string, ...any) {}
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Level int
+
+const (
+	LevelDebug = Level(slog.LevelDebug)
+	LevelInfo  = Level(slog.LevelInfo)
+	LevelWarn  = Level(slog.LevelWarn)
+	LevelError = Level(slog.LevelError)
+)
+
+func (l Level) String() string {
+	return slog.Level(l).String()
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error", case insensitively.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Logger receives the log records of all packages in this module. args are
+// alternating keys and values, as in log/slog. Records below the configured
+// level are filtered out before they reach the Logger.
+type Logger interface {
+	Log(ctx context.Context, level Level, msg string, args ...any)
+}
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	// Format is FormatText (default) or FormatJSON.
+	Format string
+	// Output defaults to os.Stderr.
+	Output io.Writer
+}
+
+// New returns a Logger writing text or JSON lines through log/slog.
+func New(opts Options) Logger {
+	if opts.Output == nil {
+		opts.Output = os.Stderr
+	}
+	// Filtering happens in this package, so the handler accepts everything.
+	handlerOpts := &slog.HandlerOptions{Level: slog.Level(LevelDebug - 4)}
+
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(opts.Output, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(opts.Output, handlerOpts)
+	}
+	return FromSlog(slog.New(handler))
+}
+
+var (
+	logger   = New(Options{})
+	minLevel = LevelInfo
+	quiet    bool
+	loggerMu sync.RWMutex
+)
+
+// SetLogger replaces the logger used by every package in this module.
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = l
+}
+
+// SetLevel sets the minimum level that is logged. Defaults to LevelInfo.
+func SetLevel(level Level) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	minLevel = level
+}
+
+// SetQuiet suppresses everything but errors while enabled, regardless of
+// the level set with SetLevel.
+func SetQuiet(enabled bool) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	quiet = enabled
+}
+
+// Enabled reports whether records at level are logged.
+func Enabled(level Level) bool {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return enabledLocked(level)
+}
+
+func enabledLocked(level Level) bool {
+	if quiet {
+		return level >= LevelError
+	}
+	return level >= minLevel
+}
+
+// Log logs msg with the key-value pairs in args at level.
+func Log(ctx context.Context, level Level, msg string, args ...any) {
+	loggerMu.RLock()
+	l, enabled := logger, enabledLocked(level)
+	loggerMu.RUnlock()
+
+	if enabled && l != nil {
+		l.Log(ctx, level, msg, args...)
+	}
+}
+
+func Debug(msg string, args ...any) {
+	Log(context.Background(), LevelDebug, msg, args...)
+}
+
+func Info(msg string, args ...any) {
+	Log(context.Background(), LevelInfo, msg, args...)
+}
+
+func Warn(msg string, args ...any) {
+	Log(context.Background(), LevelWarn, msg, args...)
+}
+
+func Error(msg string, args ...any) {
+	Log(context.Background(), LevelError, msg, args...)
+}
+
+// Fatal logs msg at LevelError and exits the process.
+func Fatal(msg string, args ...any) {
+	Error(msg, args...)
+	os.Exit(1)
+}
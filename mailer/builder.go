@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Builder composes a Message step by step:
+//
+//	mailer.New().To("a@example.com").Subject("Hi").HTML(body).Attach("report.pdf").Send(ctx)
+type Builder struct {
+	msg Message
+}
+
+// New starts building a message.
+func New() *Builder {
+	return &Builder{}
+}
+
+// From sets the sender address and display name. The address must be allowed
+// by Config.AllowedSenders.
+func (b *Builder) From(address string, name string) *Builder {
+	b.msg.From = address
+	b.msg.FromName = name
+	return b
+}
+
+func (b *Builder) To(addresses ...string) *Builder {
+	b.msg.To = append(b.msg.To, addresses...)
+	return b
+}
+
+func (b *Builder) Cc(addresses ...string) *Builder {
+	b.msg.Cc = append(b.msg.Cc, addresses...)
+	return b
+}
+
+func (b *Builder) Bcc(addresses ...string) *Builder {
+	b.msg.Bcc = append(b.msg.Bcc, addresses...)
+	return b
+}
+
+func (b *Builder) ReplyTo(address string) *Builder {
+	b.msg.ReplyTo = address
+	return b
+}
+
+func (b *Builder) Subject(subject string) *Builder {
+	b.msg.Subject = subject
+	return b
+}
+
+// HTML sets an HTML body. Combined with Text, the text is sent as its
+// plain-text alternative.
+func (b *Builder) HTML(body string) *Builder {
+	if b.msg.Body != "" && !isHTML(b.msg.BodyType) {
+		b.msg.TextBody = b.msg.Body
+	}
+	b.msg.BodyType = "text/html"
+	b.msg.Body = body
+	return b
+}
+
+// Text sets a plain-text body, or the plain-text alternative when HTML is set.
+func (b *Builder) Text(body string) *Builder {
+	if isHTML(b.msg.BodyType) {
+		b.msg.TextBody = body
+		return b
+	}
+	b.msg.BodyType = "text/plain"
+	b.msg.Body = body
+	return b
+}
+
+// AutoText generates the plain-text alternative from the HTML body.
+func (b *Builder) AutoText() *Builder {
+	b.msg.AutoTextBody = true
+	return b
+}
+
+// Body sets a body of an arbitrary content type.
+func (b *Builder) Body(bodyType string, body string) *Builder {
+	b.msg.BodyType = bodyType
+	b.msg.Body = body
+	return b
+}
+
+// Attach attaches files from disk.
+func (b *Builder) Attach(paths ...string) *Builder {
+	b.msg.Attachments = append(b.msg.Attachments, paths...)
+	return b
+}
+
+// AttachReader attaches content streamed from r. See Message.AttachReader.
+func (b *Builder) AttachReader(filename string, r io.Reader, contentType string) *Builder {
+	b.msg.AttachReader(filename, r, contentType)
+	return b
+}
+
+// Embed embeds a file that an HTML body references as cid:<contentID>.
+func (b *Builder) Embed(contentID string, path string) *Builder {
+	b.msg.Inline = append(b.msg.Inline, InlineFile{ContentID: contentID, Path: path})
+	return b
+}
+
+func (b *Builder) Invite(invite *Invite) *Builder {
+	b.msg.Invite = invite
+	return b
+}
+
+// Header adds a custom header value.
+func (b *Builder) Header(field string, values ...string) *Builder {
+	if b.msg.Headers == nil {
+		b.msg.Headers = map[string][]string{}
+	}
+	b.msg.Headers[field] = append(b.msg.Headers[field], values...)
+	return b
+}
+
+// Priority sets PriorityHigh, PriorityNormal or PriorityLow.
+func (b *Builder) Priority(priority string) *Builder {
+	b.msg.Priority = priority
+	return b
+}
+
+// ReadReceipt requests a read receipt sent to address.
+func (b *Builder) ReadReceipt(address string) *Builder {
+	b.msg.ReadReceiptTo = address
+	return b
+}
+
+func (b *Builder) Track() *Builder {
+	b.msg.Track = true
+	return b
+}
+
+// Message returns the message built so far.
+func (b *Builder) Message() Message {
+	return b.msg
+}
+
+// Send sends the message synchronously.
+func (b *Builder) Send(ctx context.Context) (*SendResult, error) {
+	return SendMessageContext(ctx, b.msg)
+}
+
+// Enqueue stores the message for asynchronous delivery. See Enqueue.
+func (b *Builder) Enqueue() (string, error) {
+	return Enqueue(b.msg)
+}
+
+// Schedule stores the message for delivery at sendAt. See ScheduleEmail.
+func (b *Builder) Schedule(sendAt time.Time) (string, error) {
+	return ScheduleEmail(b.msg, sendAt)
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+type Recipient struct {
+	Email string
+	Data  any
+}
+
+type RecipientResult struct {
+	Email     string
+	MessageID string
+	Error     error
+}
+
+type BulkReport struct {
+	Sent    int
+	Failed  int
+	Results []RecipientResult
+}
+
+// SendBulk renders the named template for every recipient and sends each
+// message individually, at most ratePerMinute messages per minute (0 means
+// unlimited). Messages share the persistent SMTP connection. Failures are
+// recorded in the report rather than aborting the run.
+func SendBulk(recipients []Recipient, templateName string, ratePerMinute int) (*BulkReport, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("mailer not initialized. Call Initialize() first")
+	}
+
+	if !templateRegistered(templateName) {
+		return nil, fmt.Errorf("template %s not registered", templateName)
+	}
+
+	var ticker *time.Ticker
+	if ratePerMinute > 0 {
+		ticker = time.NewTicker(time.Minute / time.Duration(ratePerMinute))
+		defer ticker.Stop()
+	}
+
+	report := &BulkReport{Results: make([]RecipientResult, 0, len(recipients))}
+	for i, recipient := range recipients {
+		if ticker != nil && i > 0 {
+			<-ticker.C
+		}
+
+		result := RecipientResult{Email: recipient.Email}
+		sent, err := sendToRecipient(recipient, templateName)
+		if err != nil {
+			result.Error = err
+			report.Failed++
+		} else {
+			result.MessageID = sent.MessageID
+			report.Sent++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	logging.Info("Bulk send finished", "template", templateName, "sent", report.Sent, "failed", report.Failed)
+	return report, nil
+}
+
+func sendToRecipient(recipient Recipient, templateName string) (*SendResult, error) {
+	msg, err := RenderTemplate(templateName, recipient.Data)
+	if err != nil {
+		return nil, err
+	}
+	msg.To = []string{recipient.Email}
+
+	return SendMessage(msg)
+}
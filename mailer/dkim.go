@@ -0,0 +1,226 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	// PrivateKey is a PEM encoded RSA or Ed25519 key. PrivateKeyFile is read
+	// instead when PrivateKey is empty.
+	PrivateKey     string
+	PrivateKeyFile string
+	// Headers lists the header fields to sign. Defaults to the common
+	// addressing, subject and content headers.
+	Headers []string
+}
+
+var defaultDKIMHeaders = []string{
+	"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-Id",
+	"Mime-Version", "Content-Type", "List-Unsubscribe",
+}
+
+type dkimSigner struct {
+	config    DKIMConfig
+	key       crypto.Signer
+	algorithm string
+}
+
+var signer *dkimSigner
+
+func newDKIMSigner(cfg DKIMConfig) (*dkimSigner, error) {
+	if cfg.Domain == "" {
+		return nil, fmt.Errorf("DKIM domain cannot be empty")
+	}
+	if cfg.Selector == "" {
+		return nil, fmt.Errorf("DKIM selector cannot be empty")
+	}
+
+	keyPEM := []byte(cfg.PrivateKey)
+	if len(keyPEM) == 0 {
+		if cfg.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("DKIM private key cannot be empty")
+		}
+		var err error
+		if keyPEM, err = os.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to read DKIM private key: %w", err)
+		}
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("DKIM private key is not PEM encoded")
+	}
+
+	var key any
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM private key: %w", err)
+	}
+
+	s := &dkimSigner{config: cfg}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s.key, s.algorithm = k, "rsa-sha256"
+	case ed25519.PrivateKey:
+		s.key, s.algorithm = k, "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+
+	if len(s.config.Headers) == 0 {
+		s.config.Headers = defaultDKIMHeaders
+	}
+	return s, nil
+}
+
+// rawMessage is an already rendered message that can be written repeatedly.
+type rawMessage []byte
+
+func (m rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m)
+	return int64(n), err
+}
+
+// messageContent returns what should be written to the wire for m: the
+// message itself, or its DKIM signed rendering when signing is configured.
+func messageContent(m *gomail.Message) (io.WriterTo, error) {
+	if signer == nil {
+		return m, nil
+	}
+
+	var raw bytes.Buffer
+	if _, err := m.WriteTo(&raw); err != nil {
+		return nil, fmt.Errorf("failed to render message: %w", err)
+	}
+
+	signed, err := signer.sign(raw.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return rawMessage(signed), nil
+}
+
+// sign returns raw with a DKIM-Signature header prepended, using relaxed
+// canonicalization for both header and body.
+func (s *dkimSigner) sign(raw []byte) ([]byte, error) {
+	split := bytes.Index(raw, []byte("\r\n\r\n"))
+	if split < 0 {
+		return nil, fmt.Errorf("message has no header/body separator")
+	}
+	header, body := raw[:split+2], raw[split+4:]
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	fields := parseHeaderFields(header)
+
+	var signedNames []string
+	var signedData strings.Builder
+	for _, name := range s.config.Headers {
+		field, ok := lastHeaderField(fields, name)
+		if !ok {
+			continue
+		}
+		signedNames = append(signedNames, strings.ToLower(name))
+		signedData.WriteString(relaxedHeader(field) + "\r\n")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%s; h=%s; bh=%s; b=",
+		s.algorithm,
+		s.config.Domain,
+		s.config.Selector,
+		strconv.FormatInt(time.Now().Unix(), 10),
+		strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+	signedData.WriteString(relaxedHeader("DKIM-Signature: " + value))
+
+	digest := sha256.Sum256([]byte(signedData.String()))
+	var signature []byte
+	var err error
+	if s.algorithm == "rsa-sha256" {
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	} else {
+		signature, err = s.key.Sign(rand.Reader, digest[:], crypto.Hash(0))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	var signed bytes.Buffer
+	signed.WriteString("DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n")
+	signed.Write(raw)
+	return signed.Bytes(), nil
+}
+
+// parseHeaderFields splits a header block into unfolded-as-written fields.
+func parseHeaderFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+func lastHeaderField(fields []string, name string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		colon := strings.IndexByte(fields[i], ':')
+		if colon > 0 && strings.EqualFold(strings.TrimSpace(fields[i][:colon]), name) {
+			return fields[i], true
+		}
+	}
+	return "", false
+}
+
+func relaxedHeader(field string) string {
+	colon := strings.IndexByte(field, ':')
+	name := strings.ToLower(strings.TrimSpace(field[:colon]))
+	value := strings.NewReplacer("\r\n", "", "\t", " ").Replace(field[colon+1:])
+	return name + ":" + strings.Join(strings.Fields(value), " ")
+}
+
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = strings.ReplaceAll(line, "\t", " ")
+		for strings.Contains(line, "  ") {
+			line = strings.ReplaceAll(line, "  ", " ")
+		}
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -1,15 +1,14 @@
 package mailer
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
-	"log"
 	"mime/multipart"
-	"os"
-	"path/filepath"
 	"sync"
+	"time"
 
-	"gopkg.in/gomail.v2"
+	"github.com/delightmichael1/go-libs/logging"
 )
 
 type Config struct {
@@ -17,6 +16,45 @@ type Config struct {
 	SMTPPort      int
 	EmailAccount  string
 	EmailPassword string
+	// SMTPSecurity is SMTPSecuritySSL for SSL-on-connect or
+	// SMTPSecuritySTARTTLS. By default SSL is used on port 465 and STARTTLS
+	// on every other port.
+	SMTPSecurity string
+	// TLSConfig is used for the SSL or STARTTLS handshake. When nil one is
+	// built from SMTPCACertFile and SMTPInsecureSkipVerify.
+	TLSConfig *tls.Config
+	// SMTPCACertFile is a PEM bundle of extra CAs to trust, e.g. for a relay
+	// with a private CA.
+	SMTPCACertFile         string
+	SMTPInsecureSkipVerify bool
+	// LocalName is the hostname sent with HELO. Defaults to "localhost".
+	LocalName string
+	// AllowedSenders are the addresses, besides EmailAccount, that messages
+	// may be sent from through Message.From.
+	AllowedSenders []string
+	// Bcc addresses are blind copied on every outgoing message, e.g. for
+	// compliance archiving.
+	Bcc []string
+	// IdleTimeout is how long the persistent SMTP connection may stay unused
+	// before it is closed. Defaults to 30 seconds.
+	IdleTimeout time.Duration
+	// Provider selects the delivery transport: ProviderSMTP (default),
+	// ProviderSendGrid, ProviderSES, ProviderMailgun or ProviderSandbox. The SMTP
+	// settings are only required when SMTP is used.
+	Provider string
+	// Failover providers are tried in order when delivery through the
+	// primary provider fails with a transient error.
+	Failover []string
+	// DKIM, when set, signs every message rendered by this package. It does
+	// not apply to SendGrid, which signs messages itself.
+	DKIM *DKIMConfig
+	// Tracking enables opt-in open and click tracking.
+	Tracking *TrackingConfig
+	// Transport replaces the provider-based transport when set.
+	Transport Transport
+	SendGrid  SendGridConfig
+	SES       SESConfig
+	Mailgun   MailgunConfig
 }
 
 var (
@@ -32,198 +70,108 @@ func Initialize(cfg Config) error {
 			err = fmt.Errorf("email account cannot be empty")
 			return
 		}
-		if cfg.EmailPassword == "" {
-			err = fmt.Errorf("email password cannot be empty")
-			return
+		if cfg.Provider == "" {
+			cfg.Provider = ProviderSMTP
 		}
-		if cfg.SMTPHost == "" {
-			err = fmt.Errorf("SMTP host cannot be empty")
-			return
+
+		configured := []Transport{}
+		if cfg.Transport != nil {
+			configured = append(configured, cfg.Transport)
+		} else {
+			for _, provider := range append([]string{cfg.Provider}, cfg.Failover...) {
+				transport, transportErr := newTransport(cfg, provider)
+				if transportErr != nil {
+					err = transportErr
+					return
+				}
+				configured = append(configured, transport)
+			}
 		}
-		if cfg.SMTPPort == 0 {
-			err = fmt.Errorf("SMTP port cannot be zero")
-			return
+
+		if cfg.Tracking != nil {
+			if err = cfg.Tracking.validate(); err != nil {
+				return
+			}
+		}
+
+		var configuredSigner *dkimSigner
+		if cfg.DKIM != nil {
+			configuredSigner, err = newDKIMSigner(*cfg.DKIM)
+			if err != nil {
+				return
+			}
+		}
+
+		if cfg.TLSConfig == nil {
+			cfg.TLSConfig, err = smtpTLSConfig(cfg)
+			if err != nil {
+				return
+			}
+		}
+
+		if cfg.IdleTimeout <= 0 {
+			cfg.IdleTimeout = 30 * time.Second
 		}
 
 		mailerConfig = cfg
+		transports = configured
+		signer = configuredSigner
+		idleStop = make(chan struct{})
+		go closeIdleConnection(cfg.IdleTimeout, idleStop)
 		isInitialized = true
-		log.Println("Mailer initialized successfully")
+		logging.Info("Mailer initialized successfully")
 	})
 	return err
 }
 
-func HandleSendEmail(mailto string, subject string, bodyType string, body string) (string, error) {
-	if !isInitialized {
-		return "", fmt.Errorf("mailer not initialized. Call Initialize() first")
-	}
-
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", mailerConfig.EmailAccount)
-	mailer.SetHeader("To", mailto)
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody(bodyType, body)
-
-	dialer := gomail.NewDialer(
-		mailerConfig.SMTPHost,
-		mailerConfig.SMTPPort,
-		mailerConfig.EmailAccount,
-		mailerConfig.EmailPassword,
-	)
-
-	if err := dialer.DialAndSend(mailer); err != nil {
-		log.Println("Error sending email:", err)
-		return "", err
-	}
-
-	log.Println("Email sent successfully!")
-
-	return "Email sent successfully!", nil
+// Deprecated: use New().To(mailto).Subject(subject).Body(bodyType, body).Send(ctx).
+func HandleSendEmail(mailto string, subject string, bodyType string, body string) (*SendResult, error) {
+	return New().To(mailto).Subject(subject).Body(bodyType, body).Send(context.Background())
 }
 
-func SendEmailWithCC(mailto string, cc []string, subject string, bodyType string, body string) (string, error) {
-	if !isInitialized {
-		return "", fmt.Errorf("mailer not initialized. Call Initialize() first")
-	}
-
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", mailerConfig.EmailAccount)
-	mailer.SetHeader("To", mailto)
-	if len(cc) > 0 {
-		mailer.SetHeader("Cc", cc...)
-	}
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody(bodyType, body)
-
-	dialer := gomail.NewDialer(
-		mailerConfig.SMTPHost,
-		mailerConfig.SMTPPort,
-		mailerConfig.EmailAccount,
-		mailerConfig.EmailPassword,
-	)
-
-	if err := dialer.DialAndSend(mailer); err != nil {
-		log.Println("Error sending email:", err)
-		return "", err
-	}
-
-	log.Println("Email sent successfully!")
-
-	return "Email sent successfully!", nil
+// Deprecated: use the Builder returned by New.
+func SendEmailWithCC(mailto string, cc []string, subject string, bodyType string, body string) (*SendResult, error) {
+	return New().To(mailto).Cc(cc...).Subject(subject).Body(bodyType, body).Send(context.Background())
 }
 
-func SendEmailWithAttachment(mailto string, subject string, bodyType string, body string, attachments []string) (string, error) {
-	if !isInitialized {
-		return "", fmt.Errorf("mailer not initialized. Call Initialize() first")
-	}
-
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", mailerConfig.EmailAccount)
-	mailer.SetHeader("To", mailto)
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody(bodyType, body)
-
-	for _, attachment := range attachments {
-		mailer.Attach(attachment)
-	}
-
-	dialer := gomail.NewDialer(
-		mailerConfig.SMTPHost,
-		mailerConfig.SMTPPort,
-		mailerConfig.EmailAccount,
-		mailerConfig.EmailPassword,
-	)
-
-	if err := dialer.DialAndSend(mailer); err != nil {
-		log.Println("Error sending email:", err)
-		return "", err
-	}
-
-	log.Println("Email sent successfully!")
+// SendEmailWithBCC sends an email with blind copies. The Bcc recipients do not
+// appear in the delivered headers.
+//
+// Deprecated: use the Builder returned by New.
+func SendEmailWithBCC(mailto string, cc []string, bcc []string, subject string, bodyType string, body string) (*SendResult, error) {
+	return New().To(mailto).Cc(cc...).Bcc(bcc...).Subject(subject).Body(bodyType, body).Send(context.Background())
+}
 
-	return "Email sent successfully!", nil
+// Deprecated: use New().Attach(paths...) instead.
+func SendEmailWithAttachment(mailto string, subject string, bodyType string, body string, attachments []string) (*SendResult, error) {
+	return New().To(mailto).Subject(subject).Body(bodyType, body).Attach(attachments...).Send(context.Background())
 }
 
-// SendEmailWithMultipartFiles sends email with files from multipart form data
-func SendEmailWithMultipartFiles(mailto string, subject string, bodyType string, body string, formFiles []*multipart.FileHeader) (string, error) {
+// SendEmailWithMultipartFiles sends email with files from multipart form data.
+// The files are streamed into the message without touching the disk.
+//
+// Deprecated: use New().AttachReader for each file instead.
+func SendEmailWithMultipartFiles(mailto string, subject string, bodyType string, body string, formFiles []*multipart.FileHeader) (*SendResult, error) {
 	if !isInitialized {
-		return "", fmt.Errorf("mailer not initialized. Call Initialize() first")
+		return nil, fmt.Errorf("mailer not initialized. Call Initialize() first")
 	}
 
 	if len(formFiles) == 0 {
-		return "", fmt.Errorf("no files provided")
+		return nil, fmt.Errorf("no files provided")
 	}
 
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", mailerConfig.EmailAccount)
-	mailer.SetHeader("To", mailto)
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody(bodyType, body)
+	builder := New().To(mailto).Subject(subject).Body(bodyType, body)
 
-	// Store temp file paths for cleanup
-	tempFiles := []string{}
-
-	// Attach each file from the multipart form
 	for _, fileHeader := range formFiles {
 		file, err := fileHeader.Open()
 		if err != nil {
-			log.Printf("Error opening file %s: %v\n", fileHeader.Filename, err)
-			cleanupTempFiles(tempFiles)
-			return "", fmt.Errorf("failed to open file %s: %w", fileHeader.Filename, err)
-		}
-
-		// Create a temporary file with the original filename to preserve extension
-		tmpDir := os.TempDir()
-		tmpFile, err := os.Create(filepath.Join(tmpDir, fileHeader.Filename))
-		if err != nil {
-			log.Printf("Error creating temp file for %s: %v\n", fileHeader.Filename, err)
-			file.Close()
-			cleanupTempFiles(tempFiles)
-			return "", fmt.Errorf("failed to create temp file: %w", err)
-		}
-
-		// Copy the uploaded file to the temporary file
-		if _, err := io.Copy(tmpFile, file); err != nil {
-			log.Printf("Error copying file %s: %v\n", fileHeader.Filename, err)
-			file.Close()
-			tmpFile.Close()
-			cleanupTempFiles(tempFiles)
-			return "", fmt.Errorf("failed to copy file %s: %w", fileHeader.Filename, err)
+			logging.Error("Error opening attachment", "file", fileHeader.Filename, "error", err)
+			return nil, fmt.Errorf("failed to open file %s: %w", fileHeader.Filename, err)
 		}
+		defer file.Close()
 
-		file.Close()
-		tmpFile.Close()
-
-		// Attach the temporary file
-		mailer.Attach(tmpFile.Name())
-		tempFiles = append(tempFiles, tmpFile.Name())
-	}
-
-	dialer := gomail.NewDialer(
-		mailerConfig.SMTPHost,
-		mailerConfig.SMTPPort,
-		mailerConfig.EmailAccount,
-		mailerConfig.EmailPassword,
-	)
-
-	if err := dialer.DialAndSend(mailer); err != nil {
-		log.Println("Error sending email:", err)
-		cleanupTempFiles(tempFiles)
-		return "", err
+		builder.AttachReader(fileHeader.Filename, file, fileHeader.Header.Get("Content-Type"))
 	}
 
-	// Clean up temporary files after sending
-	cleanupTempFiles(tempFiles)
-
-	log.Println("Email sent successfully with attachments!")
-
-	return "Email sent successfully with attachments!", nil
-}
-
-// cleanupTempFiles removes temporary files
-func cleanupTempFiles(files []string) {
-	for _, f := range files {
-		if err := os.Remove(f); err != nil {
-			log.Printf("Warning: failed to remove temp file %s: %v\n", f, err)
-		}
-	}
+	return builder.Send(context.Background())
 }
@@ -0,0 +1,144 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InviteMethodRequest = "REQUEST"
+	InviteMethodCancel  = "CANCEL"
+)
+
+type Attendee struct {
+	Email string `bson:"email" json:"email"`
+	Name  string `bson:"name,omitempty" json:"name,omitempty"`
+	// Optional attendees are invited with ROLE=OPT-PARTICIPANT.
+	Optional bool `bson:"optional,omitempty" json:"optional,omitempty"`
+}
+
+// Invite describes a calendar event sent as an iCalendar (RFC 5545) invite.
+// Updates and cancellations must reuse the UID of the original invite with a
+// higher Sequence.
+type Invite struct {
+	UID            string     `bson:"uid" json:"uid"`
+	Method         string     `bson:"method" json:"method"`
+	Sequence       int        `bson:"sequence" json:"sequence"`
+	OrganizerEmail string     `bson:"organizerEmail" json:"organizerEmail"`
+	OrganizerName  string     `bson:"organizerName,omitempty" json:"organizerName,omitempty"`
+	Attendees      []Attendee `bson:"attendees" json:"attendees"`
+	Summary        string     `bson:"summary" json:"summary"`
+	Description    string     `bson:"description,omitempty" json:"description,omitempty"`
+	Location       string     `bson:"location,omitempty" json:"location,omitempty"`
+	Start          time.Time  `bson:"start" json:"start"`
+	End            time.Time  `bson:"end" json:"end"`
+}
+
+// BuildICS renders invite as an iCalendar document. A missing UID is
+// generated and written back to invite so it can be stored for later updates.
+func BuildICS(invite *Invite) (string, error) {
+	if invite.Method == "" {
+		invite.Method = InviteMethodRequest
+	}
+	if invite.Method != InviteMethodRequest && invite.Method != InviteMethodCancel {
+		return "", fmt.Errorf("unsupported invite method: %s", invite.Method)
+	}
+	if invite.OrganizerEmail == "" {
+		return "", fmt.Errorf("invite organizer cannot be empty")
+	}
+	if invite.Start.IsZero() || !invite.End.After(invite.Start) {
+		return "", fmt.Errorf("invite must end after it starts")
+	}
+	if invite.UID == "" {
+		invite.UID = uuid.NewString()
+	}
+
+	status := "CONFIRMED"
+	if invite.Method == InviteMethodCancel {
+		status = "CANCELLED"
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//go-libs//mailer//EN",
+		"VERSION:2.0",
+		"CALSCALE:GREGORIAN",
+		"METHOD:" + invite.Method,
+		"BEGIN:VEVENT",
+		"UID:" + invite.UID,
+		"SEQUENCE:" + fmt.Sprint(invite.Sequence),
+		"DTSTAMP:" + icsTime(time.Now()),
+		"DTSTART:" + icsTime(invite.Start),
+		"DTEND:" + icsTime(invite.End),
+		"SUMMARY:" + icsEscape(invite.Summary),
+		"STATUS:" + status,
+		"ORGANIZER" + icsName(invite.OrganizerName) + ":mailto:" + invite.OrganizerEmail,
+	}
+	if invite.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+icsEscape(invite.Description))
+	}
+	if invite.Location != "" {
+		lines = append(lines, "LOCATION:"+icsEscape(invite.Location))
+	}
+	for _, attendee := range invite.Attendees {
+		role := "REQ-PARTICIPANT"
+		if attendee.Optional {
+			role = "OPT-PARTICIPANT"
+		}
+		lines = append(lines, "ATTENDEE;ROLE="+role+";PARTSTAT=NEEDS-ACTION;RSVP=TRUE"+
+			icsName(attendee.Name)+":mailto:"+attendee.Email)
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var ics strings.Builder
+	for _, line := range lines {
+		ics.WriteString(icsFold(line))
+		ics.WriteString("\r\n")
+	}
+	return ics.String(), nil
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func icsName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return `;CN="` + strings.ReplaceAll(name, `"`, "'") + `"`
+}
+
+func icsEscape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// icsFold splits content lines longer than 75 octets as required by RFC 5545,
+// without breaking multi-byte characters.
+func icsFold(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+
+	var folded strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			folded.WriteString("\r\n ")
+			width = 1
+		}
+		folded.WriteRune(r)
+		width += size
+	}
+	return folded.String()
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+)
+
+type MailgunConfig struct {
+	Domain string
+	APIKey string
+	// BaseURL defaults to https://api.mailgun.net. EU domains use
+	// https://api.eu.mailgun.net.
+	BaseURL string
+}
+
+type mailgunTransport struct {
+	config MailgunConfig
+}
+
+func newMailgunTransport(cfg MailgunConfig) (Transport, error) {
+	if cfg.Domain == "" {
+		return nil, fmt.Errorf("Mailgun domain cannot be empty")
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("Mailgun API key cannot be empty")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.mailgun.net"
+	}
+	return &mailgunTransport{config: cfg}, nil
+}
+
+func (t *mailgunTransport) Name() string {
+	return ProviderMailgun
+}
+
+// Send delivers the message through Mailgun's MIME endpoint, so the message is
+// sent exactly as rendered locally.
+func (t *mailgunTransport) Send(ctx context.Context, msg Message) error {
+	raw, _, to, err := renderMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, recipient := range to {
+		if err := form.WriteField("to", recipient); err != nil {
+			return fmt.Errorf("failed to build Mailgun request: %w", err)
+		}
+	}
+	part, err := form.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+	if _, err := part.Write(raw); err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v3/%s/messages.mime", t.config.BaseURL, t.config.Domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create Mailgun request: %w", err)
+	}
+	req.SetBasicAuth("api", t.config.APIKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	return doAPIRequest(req, ProviderMailgun)
+}
@@ -0,0 +1,323 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+var priorityHeaders = map[string]string{
+	PriorityHigh:   "1 (Highest)",
+	PriorityNormal: "3 (Normal)",
+	PriorityLow:    "5 (Lowest)",
+}
+
+// Message describes an email independently of how it is delivered, so it can
+// be queued and persisted.
+type Message struct {
+	// From overrides the sending address. It must be the configured
+	// EmailAccount or one of Config.AllowedSenders. FromName sets the display
+	// name shown to recipients.
+	From     string `bson:"from,omitempty" json:"from,omitempty"`
+	FromName string `bson:"fromName,omitempty" json:"fromName,omitempty"`
+	// MessageID is generated when empty and sent as the Message-Id header.
+	MessageID string   `bson:"messageId,omitempty" json:"messageId,omitempty"`
+	To        []string `bson:"to" json:"to"`
+	Cc        []string `bson:"cc,omitempty" json:"cc,omitempty"`
+	Bcc       []string `bson:"bcc,omitempty" json:"bcc,omitempty"`
+	ReplyTo   string   `bson:"replyTo,omitempty" json:"replyTo,omitempty"`
+	Subject   string   `bson:"subject" json:"subject"`
+	BodyType  string   `bson:"bodyType" json:"bodyType"`
+	Body      string   `bson:"body" json:"body"`
+	// TextBody is sent as a plain-text alternative to an HTML Body. With
+	// AutoTextBody set it is generated from the HTML when left empty.
+	TextBody     string   `bson:"textBody,omitempty" json:"textBody,omitempty"`
+	AutoTextBody bool     `bson:"autoTextBody,omitempty" json:"autoTextBody,omitempty"`
+	Attachments  []string `bson:"attachments,omitempty" json:"attachments,omitempty"`
+	// Inline images are embedded in the message and referenced from an HTML
+	// body as cid:<ContentID>.
+	Inline []InlineFile `bson:"inline,omitempty" json:"inline,omitempty"`
+	// Invite attaches a calendar invite that mail clients show as an
+	// actionable event. Set Invite.UID to be able to update or cancel the
+	// event later.
+	Invite *Invite `bson:"invite,omitempty" json:"invite,omitempty"`
+	// Priority is PriorityHigh, PriorityNormal or PriorityLow. It sets the
+	// X-Priority and Importance headers that mail clients use to flag messages.
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`
+	// ReadReceiptTo requests a read receipt sent to this address through the
+	// Disposition-Notification-To header.
+	ReadReceiptTo string `bson:"readReceiptTo,omitempty" json:"readReceiptTo,omitempty"`
+	// Track enables open and click tracking when Config.Tracking is set.
+	Track bool `bson:"track,omitempty" json:"track,omitempty"`
+	// Headers are extra headers such as List-Unsubscribe or X-* headers.
+	// Address and subject headers must be set through their dedicated fields.
+	Headers map[string][]string `bson:"headers,omitempty" json:"headers,omitempty"`
+
+	readers  []*readerAttachment
+	calendar string
+}
+
+type readerAttachment struct {
+	filename    string
+	contentType string
+	reader      io.Reader
+	consumed    bool
+}
+
+// AttachReader attaches content streamed from r, so uploads can be forwarded
+// without writing them to disk first. Readers are not persisted, so messages
+// with reader attachments cannot be enqueued. If r is an io.Seeker it is
+// rewound before every delivery attempt; otherwise it can only be sent once.
+func (m *Message) AttachReader(filename string, r io.Reader, contentType string) {
+	m.readers = append(m.readers, &readerAttachment{
+		filename:    filename,
+		contentType: contentType,
+		reader:      r,
+	})
+}
+
+func (a *readerAttachment) copyFunc() func(io.Writer) error {
+	return func(w io.Writer) error {
+		if seeker, ok := a.reader.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind attachment %s: %w", a.filename, err)
+			}
+		} else if a.consumed {
+			return fmt.Errorf("attachment %s cannot be read twice", a.filename)
+		}
+		a.consumed = true
+		_, err := io.Copy(w, a.reader)
+		return err
+	}
+}
+
+type InlineFile struct {
+	ContentID string `bson:"contentId" json:"contentId"`
+	Path      string `bson:"path" json:"path"`
+}
+
+var reservedHeaders = map[string]bool{
+	"From":       true,
+	"Sender":     true,
+	"To":         true,
+	"Cc":         true,
+	"Bcc":        true,
+	"Subject":    true,
+	"Message-Id": true,
+}
+
+// prepareMessage validates msg and applies the configured defaults, returning
+// the message exactly as the transports should deliver it.
+func prepareMessage(msg Message) (Message, error) {
+	if len(msg.To) == 0 {
+		return msg, fmt.Errorf("message has no recipients")
+	}
+
+	for field := range msg.Headers {
+		if reservedHeaders[textproto.CanonicalMIMEHeaderKey(field)] {
+			return msg, fmt.Errorf("header %s cannot be set as a custom header", field)
+		}
+	}
+
+	for _, inline := range msg.Inline {
+		if inline.ContentID == "" {
+			return msg, fmt.Errorf("inline file %s has no content ID", inline.Path)
+		}
+	}
+
+	if msg.Priority != "" || msg.ReadReceiptTo != "" {
+		headers := make(map[string][]string, len(msg.Headers)+3)
+		for field, values := range msg.Headers {
+			headers[field] = values
+		}
+		if msg.Priority != "" {
+			xPriority, ok := priorityHeaders[msg.Priority]
+			if !ok {
+				return msg, fmt.Errorf("unsupported priority: %s", msg.Priority)
+			}
+			headers["X-Priority"] = []string{xPriority}
+			headers["Importance"] = []string{msg.Priority}
+		}
+		if msg.ReadReceiptTo != "" {
+			addr, err := mail.ParseAddress(msg.ReadReceiptTo)
+			if err != nil {
+				return msg, fmt.Errorf("invalid read receipt address %q: %w", msg.ReadReceiptTo, err)
+			}
+			headers["Disposition-Notification-To"] = []string{addr.String()}
+		}
+		msg.Headers = headers
+	}
+
+	from, err := resolveSender(msg.From)
+	if err != nil {
+		return msg, err
+	}
+	msg.From = from
+
+	if msg.TextBody == "" && msg.AutoTextBody && isHTML(msg.BodyType) {
+		msg.TextBody = HTMLToText(msg.Body)
+	}
+
+	if msg.MessageID == "" {
+		msg.MessageID = uuid.NewString() + "@" + from[strings.LastIndex(from, "@")+1:]
+	}
+
+	if msg.Track && mailerConfig.Tracking != nil && isHTML(msg.BodyType) {
+		msg.Body = applyTracking(msg.Body, msg.MessageID)
+	}
+
+	if msg.Invite != nil {
+		invite := *msg.Invite
+		if msg.calendar, err = BuildICS(&invite); err != nil {
+			return msg, err
+		}
+		msg.Invite = &invite
+	}
+
+	if len(mailerConfig.Bcc) > 0 {
+		msg.Bcc = append(append([]string{}, msg.Bcc...), mailerConfig.Bcc...)
+	}
+
+	return msg, nil
+}
+
+// buildMessage renders a prepared message into a MIME message.
+func buildMessage(msg Message) *gomail.Message {
+	mailer := gomail.NewMessage()
+	mailer.SetAddressHeader("From", msg.From, msg.FromName)
+	mailer.SetHeader("To", msg.To...)
+	if len(msg.Cc) > 0 {
+		mailer.SetHeader("Cc", msg.Cc...)
+	}
+	if len(msg.Bcc) > 0 {
+		mailer.SetHeader("Bcc", msg.Bcc...)
+	}
+	if msg.ReplyTo != "" {
+		mailer.SetHeader("Reply-To", msg.ReplyTo)
+	}
+	mailer.SetHeader("Subject", msg.Subject)
+	mailer.SetHeader("Message-Id", "<"+msg.MessageID+">")
+
+	for field, values := range msg.Headers {
+		mailer.SetHeader(textproto.CanonicalMIMEHeaderKey(field), values...)
+	}
+
+	if msg.TextBody != "" && isHTML(msg.BodyType) {
+		// Clients prefer the last alternative they can display.
+		mailer.SetBody("text/plain", msg.TextBody)
+		mailer.AddAlternative(msg.BodyType, msg.Body)
+	} else {
+		mailer.SetBody(msg.BodyType, msg.Body)
+	}
+
+	if msg.calendar != "" {
+		// Gmail and Outlook only offer accept/decline when the calendar is an
+		// alternative of the body; the attachment serves other clients.
+		calendarType := "text/calendar; method=" + msg.Invite.Method
+		mailer.AddAlternative(calendarType, msg.calendar)
+		mailer.Attach("invite.ics",
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := io.WriteString(w, msg.calendar)
+				return err
+			}),
+			gomail.SetHeader(map[string][]string{
+				"Content-Type": {"application/ics; name=\"invite.ics\""},
+			}),
+		)
+	}
+
+	for _, attachment := range msg.Attachments {
+		mailer.Attach(attachment)
+	}
+
+	for _, attachment := range msg.readers {
+		settings := []gomail.FileSetting{gomail.SetCopyFunc(attachment.copyFunc())}
+		if attachment.contentType != "" {
+			settings = append(settings, gomail.SetHeader(map[string][]string{
+				"Content-Type": {attachment.contentType},
+			}))
+		}
+		mailer.Attach(attachment.filename, settings...)
+	}
+
+	for _, inline := range msg.Inline {
+		mailer.Embed(inline.Path, gomail.SetHeader(map[string][]string{
+			"Content-ID": {"<" + inline.ContentID + ">"},
+		}))
+	}
+
+	return mailer
+}
+
+// resolveSender returns the address to send from, rejecting identities that
+// are not allowed.
+func resolveSender(from string) (string, error) {
+	if from == "" {
+		return mailerConfig.EmailAccount, nil
+	}
+
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+	if strings.EqualFold(addr.Address, mailerConfig.EmailAccount) {
+		return addr.Address, nil
+	}
+	for _, allowed := range mailerConfig.AllowedSenders {
+		if strings.EqualFold(addr.Address, allowed) {
+			return addr.Address, nil
+		}
+	}
+	return "", fmt.Errorf("sender %s is not an allowed identity", addr.Address)
+}
+
+func isHTML(bodyType string) bool {
+	return strings.HasPrefix(bodyType, "text/html")
+}
+
+// SendMessage sends msg synchronously.
+func SendMessage(msg Message) (*SendResult, error) {
+	return SendMessageContext(context.Background(), msg)
+}
+
+// SendMessageContext sends msg synchronously, aborting API requests to the
+// email provider when ctx is done.
+func SendMessageContext(ctx context.Context, msg Message) (*SendResult, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("mailer not initialized. Call Initialize() first")
+	}
+
+	msg, err := prepareMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := deliver(ctx, msg)
+	if err != nil {
+		logging.Error("Error sending email", "messageId", msg.MessageID, "error", err)
+		return nil, err
+	}
+
+	logging.Info("Email sent", "messageId", msg.MessageID, "transport", transport)
+
+	return &SendResult{
+		MessageID:  msg.MessageID,
+		Recipients: envelopeRecipients(msg),
+		Transport:  transport,
+		SentAt:     time.Now(),
+	}, nil
+}
@@ -0,0 +1,335 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	QueueStatusPending    = "pending"
+	QueueStatusProcessing = "processing"
+	QueueStatusDead       = "dead"
+)
+
+// QueuedEmail is a message waiting in the queue together with its delivery state.
+type QueuedEmail struct {
+	ID            string    `bson:"_id" json:"id"`
+	Message       Message   `bson:"message" json:"message"`
+	Status        string    `bson:"status" json:"status"`
+	Attempts      int       `bson:"attempts" json:"attempts"`
+	LastError     string    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt time.Time `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// QueueStore persists queued emails. Claim must atomically hand out a due
+// email to a single worker and lease it until leaseUntil; a leased email that
+// is not updated in time becomes claimable again.
+type QueueStore interface {
+	Push(ctx context.Context, email *QueuedEmail) error
+	Claim(ctx context.Context, now time.Time, leaseUntil time.Time) (*QueuedEmail, error)
+	// Update and Delete only apply while the email is still processing under
+	// the lease given by leaseUntil, and report whether they did. This keeps a
+	// worker whose lease expired from overwriting a newer claim.
+	Update(ctx context.Context, email *QueuedEmail, leaseUntil time.Time) (bool, error)
+	Delete(ctx context.Context, id string, leaseUntil time.Time) (bool, error)
+	// Cancel removes an email that is still pending and reports whether it did.
+	Cancel(ctx context.Context, id string) (bool, error)
+	Depth(ctx context.Context) (int64, error)
+	DeadLetters(ctx context.Context) ([]QueuedEmail, error)
+}
+
+type QueueConfig struct {
+	Workers        int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	PollInterval   time.Duration
+	// Lease is how long a worker may hold an email before it is handed out again.
+	Lease time.Duration
+	// Store defaults to an in-memory store, which loses pending emails on restart.
+	Store QueueStore
+}
+
+var (
+	queueConfig QueueConfig
+	queueMu     sync.Mutex
+	queueWake   chan struct{}
+	queueCancel context.CancelFunc
+	queueDone   sync.WaitGroup
+)
+
+// StartQueue starts the background workers that deliver enqueued emails.
+func StartQueue(cfg QueueConfig) error {
+	if !isInitialized {
+		return fmt.Errorf("mailer not initialized. Call Initialize() first")
+	}
+
+	queueMu.Lock()
+	defer queueMu.Unlock()
+
+	if queueCancel != nil {
+		return fmt.Errorf("email queue already started")
+	}
+
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 30 * time.Minute
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Lease == 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryQueueStore()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queueConfig = cfg
+	queueWake = make(chan struct{}, cfg.Workers)
+	queueCancel = cancel
+
+	for i := 0; i < cfg.Workers; i++ {
+		queueDone.Add(1)
+		go runQueueWorker(ctx)
+	}
+
+	logging.Info("Email queue started", "workers", cfg.Workers)
+	return nil
+}
+
+// StopQueue stops the workers and waits for in-flight deliveries to finish.
+func StopQueue() {
+	queueMu.Lock()
+	cancel := queueCancel
+	queueCancel = nil
+	queueMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	queueDone.Wait()
+}
+
+// Enqueue stores msg for asynchronous delivery and returns its queue ID.
+func Enqueue(msg Message) (string, error) {
+	return enqueueAt(msg, time.Now())
+}
+
+// ScheduleEmail stores msg for delivery at sendAt and returns an ID that can
+// be passed to CancelScheduledEmail. Schedules only survive restarts when the
+// queue uses a persistent store such as NewMongoQueueStore.
+func ScheduleEmail(msg Message, sendAt time.Time) (string, error) {
+	return enqueueAt(msg, sendAt)
+}
+
+// CancelScheduledEmail cancels a scheduled or queued email that has not been
+// picked up for delivery yet.
+func CancelScheduledEmail(ctx context.Context, id string) error {
+	store, err := queueStore()
+	if err != nil {
+		return err
+	}
+
+	cancelled, err := store.Cancel(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel email %s: %w", id, err)
+	}
+	if !cancelled {
+		return fmt.Errorf("email %s not found or already being delivered", id)
+	}
+	return nil
+}
+
+func enqueueAt(msg Message, sendAt time.Time) (string, error) {
+	queueMu.Lock()
+	started := queueCancel != nil
+	store, wake := queueConfig.Store, queueWake
+	queueMu.Unlock()
+	if !started {
+		return "", fmt.Errorf("email queue not started. Call StartQueue() first")
+	}
+
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("message has no recipients")
+	}
+	if len(msg.readers) > 0 {
+		return "", fmt.Errorf("messages with reader attachments cannot be enqueued")
+	}
+
+	email := &QueuedEmail{
+		ID:            uuid.NewString(),
+		Message:       msg,
+		Status:        QueueStatusPending,
+		NextAttemptAt: sendAt,
+		CreatedAt:     time.Now(),
+	}
+	if err := store.Push(context.Background(), email); err != nil {
+		return "", fmt.Errorf("failed to enqueue email: %w", err)
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return email.ID, nil
+}
+
+// QueueDepth returns the number of emails waiting for delivery, including
+// scheduled ones.
+func QueueDepth(ctx context.Context) (int64, error) {
+	store, err := queueStore()
+	if err != nil {
+		return 0, err
+	}
+	return store.Depth(ctx)
+}
+
+// DeadLetters returns the emails that exhausted their attempts or failed permanently.
+func DeadLetters(ctx context.Context) ([]QueuedEmail, error) {
+	store, err := queueStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.DeadLetters(ctx)
+}
+
+// queueStore returns the store set by StartQueue. It stays available after
+// StopQueue so pending emails can still be inspected.
+func queueStore() (QueueStore, error) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	if queueConfig.Store == nil {
+		return nil, fmt.Errorf("email queue not started. Call StartQueue() first")
+	}
+	return queueConfig.Store, nil
+}
+
+func runQueueWorker(ctx context.Context) {
+	defer queueDone.Done()
+
+	ticker := time.NewTicker(queueConfig.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for processNextEmail(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-queueWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNextEmail delivers one due email and reports whether there was one.
+func processNextEmail(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	now := time.Now()
+	email, err := queueConfig.Store.Claim(ctx, now, now.Add(queueConfig.Lease))
+	if err != nil {
+		logging.Error("Failed to claim queued email", "error", err)
+		return false
+	}
+	if email == nil {
+		return false
+	}
+
+	// Claim stores the lease in NextAttemptAt.
+	leaseUntil := email.NextAttemptAt
+	// The outcome is recorded even when StopQueue cancels ctx, so delivered
+	// emails are not sent again.
+	recordCtx := context.WithoutCancel(ctx)
+
+	_, sendErr := SendMessage(email.Message)
+	if sendErr == nil {
+		deleted, err := queueConfig.Store.Delete(recordCtx, email.ID, leaseUntil)
+		if err != nil {
+			logging.Error("Failed to remove delivered email from queue", "id", email.ID, "error", err)
+		} else if !deleted {
+			logging.Warn("Lease of delivered email expired before it was removed", "id", email.ID)
+		}
+		return true
+	}
+
+	email.Attempts++
+	email.LastError = sendErr.Error()
+	if isTransientError(sendErr) && email.Attempts < queueConfig.MaxAttempts {
+		email.Status = QueueStatusPending
+		email.NextAttemptAt = time.Now().Add(queueBackoff(email.Attempts))
+	} else {
+		email.Status = QueueStatusDead
+		logging.Error("Email moved to dead letters", "id", email.ID, "attempts", email.Attempts, "error", sendErr)
+	}
+
+	updated, err := queueConfig.Store.Update(recordCtx, email, leaseUntil)
+	if err != nil {
+		logging.Error("Failed to update queued email", "id", email.ID, "error", err)
+	} else if !updated {
+		logging.Warn("Lease of queued email expired before its outcome was recorded", "id", email.ID)
+	}
+	return true
+}
+
+func queueBackoff(attempts int) time.Duration {
+	backoff := queueConfig.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= queueConfig.MaxBackoff {
+			return queueConfig.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// isTransientError reports whether a delivery may succeed when retried. SMTP
+// 4xx replies, API throttling and server errors, timeouts and connection
+// problems are transient; anything else, such as an invalid message, is
+// permanent.
+func isTransientError(err error) bool {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code < 500
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.Is(err, ErrConnection) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
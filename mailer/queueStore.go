@@ -0,0 +1,233 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type memoryQueueStore struct {
+	mu     sync.Mutex
+	emails map[string]*QueuedEmail
+}
+
+// NewMemoryQueueStore returns a QueueStore that keeps emails in process memory.
+func NewMemoryQueueStore() QueueStore {
+	return &memoryQueueStore{emails: map[string]*QueuedEmail{}}
+}
+
+func (s *memoryQueueStore) Push(ctx context.Context, email *QueuedEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *email
+	s.emails[email.ID] = &stored
+	return nil
+}
+
+func (s *memoryQueueStore) Claim(ctx context.Context, now time.Time, leaseUntil time.Time) (*QueuedEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *QueuedEmail
+	for _, email := range s.emails {
+		if email.Status == QueueStatusDead || email.NextAttemptAt.After(now) {
+			continue
+		}
+		if next == nil || email.NextAttemptAt.Before(next.NextAttemptAt) {
+			next = email
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Status = QueueStatusProcessing
+	next.NextAttemptAt = leaseUntil
+	claimed := *next
+	return &claimed, nil
+}
+
+func (s *memoryQueueStore) Update(ctx context.Context, email *QueuedEmail, leaseUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.leased(email.ID, leaseUntil) {
+		return false, nil
+	}
+	stored := *email
+	s.emails[email.ID] = &stored
+	return true, nil
+}
+
+func (s *memoryQueueStore) Delete(ctx context.Context, id string, leaseUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.leased(id, leaseUntil) {
+		return false, nil
+	}
+	delete(s.emails, id)
+	return true, nil
+}
+
+func (s *memoryQueueStore) leased(id string, leaseUntil time.Time) bool {
+	email, ok := s.emails[id]
+	return ok && email.Status == QueueStatusProcessing && email.NextAttemptAt.Equal(leaseUntil)
+}
+
+func (s *memoryQueueStore) Cancel(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email, ok := s.emails[id]
+	if !ok || email.Status != QueueStatusPending {
+		return false, nil
+	}
+	delete(s.emails, id)
+	return true, nil
+}
+
+func (s *memoryQueueStore) Depth(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var depth int64
+	for _, email := range s.emails {
+		if email.Status != QueueStatusDead {
+			depth++
+		}
+	}
+	return depth, nil
+}
+
+func (s *memoryQueueStore) DeadLetters(ctx context.Context) ([]QueuedEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dead []QueuedEmail
+	for _, email := range s.emails {
+		if email.Status == QueueStatusDead {
+			dead = append(dead, *email)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		return dead[i].CreatedAt.Before(dead[j].CreatedAt)
+	})
+	return dead, nil
+}
+
+type mongoQueueStore struct {
+	collectionName string
+}
+
+// NewMongoQueueStore returns a QueueStore backed by a MongoDB collection. The
+// storage package must be initialized before the queue is started.
+func NewMongoQueueStore(collectionName string) QueueStore {
+	return &mongoQueueStore{collectionName: collectionName}
+}
+
+func (s *mongoQueueStore) collection(ctx context.Context) (*mongo.Collection, error) {
+	collection := storage.GetCollectionRef(ctx, s.collectionName)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", s.collectionName)
+	}
+	return collection, nil
+}
+
+func (s *mongoQueueStore) Push(ctx context.Context, email *QueuedEmail) error {
+	_, err := storage.InsertData(ctx, s.collectionName, email)
+	return err
+}
+
+func (s *mongoQueueStore) Claim(ctx context.Context, now time.Time, leaseUntil time.Time) (*QueuedEmail, error) {
+	collection, err := s.collection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"status":        bson.M{"$in": []string{QueueStatusPending, QueueStatusProcessing}},
+		"nextAttemptAt": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":        QueueStatusProcessing,
+		"nextAttemptAt": leaseUntil,
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"nextAttemptAt": 1}).
+		SetReturnDocument(options.After)
+
+	var email QueuedEmail
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&email); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim queued email: %w", err)
+	}
+	return &email, nil
+}
+
+func (s *mongoQueueStore) Update(ctx context.Context, email *QueuedEmail, leaseUntil time.Time) (bool, error) {
+	result, err := storage.UpdateOne(ctx, s.collectionName, leasedFilter(email.ID, leaseUntil), bson.M{
+		"status":        email.Status,
+		"attempts":      email.Attempts,
+		"lastError":     email.LastError,
+		"nextAttemptAt": email.NextAttemptAt,
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (s *mongoQueueStore) Delete(ctx context.Context, id string, leaseUntil time.Time) (bool, error) {
+	result, err := storage.DeleteOne(ctx, s.collectionName, leasedFilter(id, leaseUntil))
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func leasedFilter(id string, leaseUntil time.Time) bson.M {
+	return bson.M{"_id": id, "status": QueueStatusProcessing, "nextAttemptAt": leaseUntil}
+}
+
+func (s *mongoQueueStore) Cancel(ctx context.Context, id string) (bool, error) {
+	result, err := storage.DeleteOne(ctx, s.collectionName, bson.M{"_id": id, "status": QueueStatusPending})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (s *mongoQueueStore) Depth(ctx context.Context) (int64, error) {
+	return storage.CountDocuments(ctx, s.collectionName, bson.M{"status": bson.M{"$ne": QueueStatusDead}})
+}
+
+func (s *mongoQueueStore) DeadLetters(ctx context.Context) ([]QueuedEmail, error) {
+	collection, err := s.collection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"createdAt": 1})
+	cursor, err := collection.Find(ctx, bson.M{"status": QueueStatusDead}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dead letters: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var dead []QueuedEmail
+	if err := cursor.All(ctx, &dead); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letters: %w", err)
+	}
+	return dead, nil
+}
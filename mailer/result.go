@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"time"
+)
+
+// SendResult describes a delivered message.
+type SendResult struct {
+	MessageID string
+	// Recipients are the envelope recipients the transport accepted.
+	Recipients []string
+	Transport  string
+	SentAt     time.Time
+}
+
+var (
+	ErrAuthFailed        = errors.New("email authentication failed")
+	ErrRecipientRejected = errors.New("email recipient rejected")
+	ErrConnection        = errors.New("email server connection failed")
+)
+
+// SendError is returned when every transport failed to deliver a message.
+// errors.Is reports whether it was caused by ErrAuthFailed,
+// ErrRecipientRejected or ErrConnection.
+type SendError struct {
+	Transport string
+	Kind      error
+	Err       error
+}
+
+func (e *SendError) Error() string {
+	return e.Transport + ": " + e.Err.Error()
+}
+
+func (e *SendError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func newSendError(transport string, err error) *SendError {
+	sendErr := &SendError{Transport: transport, Err: err}
+
+	var reply *textproto.Error
+	var apiErr *APIError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrConnection):
+	case errors.As(err, &reply):
+		switch reply.Code {
+		case 530, 534, 535, 538:
+			sendErr.Kind = ErrAuthFailed
+		case 550, 551, 553:
+			sendErr.Kind = ErrRecipientRejected
+		}
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden {
+			sendErr.Kind = ErrAuthFailed
+		}
+	case errors.As(err, &netErr):
+		sendErr.Kind = ErrConnection
+	}
+	return sendErr
+}
+
+// envelopeRecipients returns the addresses a prepared message is delivered to.
+func envelopeRecipients(msg Message) []string {
+	var recipients []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, value := range list {
+			if addr, err := mail.ParseAddress(value); err == nil {
+				recipients = append(recipients, addr.Address)
+			}
+		}
+	}
+	return recipients
+}
@@ -0,0 +1,171 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxEmail is a message captured by the sandbox transport.
+type OutboxEmail struct {
+	MessageID string
+	From      string
+	// To, Cc and Bcc are the recipients as given on the message. Recipients
+	// lists every envelope recipient the message was delivered to.
+	To         []string
+	Cc         []string
+	Bcc        []string
+	Recipients []string
+	Subject    string
+	Headers    mail.Header
+	// HTMLBody and TextBody are the decoded body parts of the rendered message.
+	HTMLBody    string
+	TextBody    string
+	Attachments []OutboxAttachment
+	// Raw is the complete message as it would have been sent.
+	Raw    []byte
+	SentAt time.Time
+}
+
+type OutboxAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Inline      bool
+	Content     []byte
+}
+
+var (
+	outbox   []OutboxEmail
+	outboxMu sync.Mutex
+)
+
+// sandboxTransport captures messages in memory instead of delivering them,
+// so services can assert on sent emails in tests.
+type sandboxTransport struct{}
+
+func (sandboxTransport) Name() string {
+	return ProviderSandbox
+}
+
+func (sandboxTransport) Send(ctx context.Context, msg Message) error {
+	raw, _, recipients, err := renderMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	email, err := parseOutboxEmail(raw)
+	if err != nil {
+		return err
+	}
+	email.MessageID = msg.MessageID
+	email.From = msg.From
+	email.To = msg.To
+	email.Cc = msg.Cc
+	email.Bcc = msg.Bcc
+	email.Recipients = recipients
+	email.Subject = msg.Subject
+	email.SentAt = time.Now()
+
+	outboxMu.Lock()
+	outbox = append(outbox, *email)
+	outboxMu.Unlock()
+	return nil
+}
+
+// Outbox returns the messages captured by the sandbox transport, oldest first.
+func Outbox() []OutboxEmail {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	return append([]OutboxEmail{}, outbox...)
+}
+
+// ResetOutbox discards the captured messages.
+func ResetOutbox() {
+	outboxMu.Lock()
+	outbox = nil
+	outboxMu.Unlock()
+}
+
+func parseOutboxEmail(raw []byte) (*OutboxEmail, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered message: %w", err)
+	}
+
+	email := &OutboxEmail{Headers: parsed.Header, Raw: raw}
+	err = email.readPart(parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"),
+		parsed.Header.Get("Content-Disposition"), parsed.Header.Get("Content-Id"), parsed.Body)
+	if err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func (e *OutboxEmail) readPart(contentType string, encoding string, disposition string, contentID string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to parse rendered message: %w", err)
+			}
+			err = e.readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part.Header.Get("Content-Id"), part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to decode rendered message: %w", err)
+	}
+
+	dispositionType, dispositionParams, _ := mime.ParseMediaType(disposition)
+	if dispositionType == "" && contentID == "" {
+		switch mediaType {
+		case "text/html":
+			e.HTMLBody = string(content)
+			return nil
+		case "text/plain":
+			e.TextBody = string(content)
+			return nil
+		case "text/calendar":
+			return nil
+		}
+	}
+
+	e.Attachments = append(e.Attachments, OutboxAttachment{
+		Filename:    dispositionParams["filename"],
+		ContentType: mediaType,
+		ContentID:   strings.Trim(contentID, "<>"),
+		Inline:      dispositionType == "inline",
+		Content:     content,
+	})
+	return nil
+}
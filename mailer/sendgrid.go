@@ -0,0 +1,199 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SendGridConfig struct {
+	APIKey string
+	// BaseURL defaults to https://api.sendgrid.com.
+	BaseURL string
+}
+
+type sendGridTransport struct {
+	config SendGridConfig
+}
+
+func newSendGridTransport(cfg SendGridConfig) (Transport, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("SendGrid API key cannot be empty")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.sendgrid.com"
+	}
+	return &sendGridTransport{config: cfg}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Filename    string `json:"filename"`
+	Type        string `json:"type,omitempty"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+func (t *sendGridTransport) Name() string {
+	return ProviderSendGrid
+}
+
+func (t *sendGridTransport) Send(ctx context.Context, msg Message) error {
+	payload, err := t.buildRequest(msg)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode SendGrid request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.BaseURL+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SendGrid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+t.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doAPIRequest(req, ProviderSendGrid)
+}
+
+func (t *sendGridTransport) buildRequest(msg Message) (*sendGridRequest, error) {
+	var err error
+	personalization := sendGridPersonalization{}
+	if personalization.To, err = sendGridAddresses(msg.To); err != nil {
+		return nil, err
+	}
+	if personalization.Cc, err = sendGridAddresses(msg.Cc); err != nil {
+		return nil, err
+	}
+	if personalization.Bcc, err = sendGridAddresses(msg.Bcc); err != nil {
+		return nil, err
+	}
+
+	payload := &sendGridRequest{
+		Personalizations: []sendGridPersonalization{personalization},
+		From:             sendGridAddress{Email: msg.From, Name: msg.FromName},
+		Subject:          msg.Subject,
+	}
+
+	if msg.TextBody != "" && isHTML(msg.BodyType) {
+		payload.Content = append(payload.Content, sendGridContent{Type: "text/plain", Value: msg.TextBody})
+	}
+	payload.Content = append(payload.Content, sendGridContent{Type: msg.BodyType, Value: msg.Body})
+	if msg.calendar != "" {
+		payload.Content = append(payload.Content, sendGridContent{
+			Type:  "text/calendar; method=" + msg.Invite.Method,
+			Value: msg.calendar,
+		})
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString([]byte(msg.calendar)),
+			Filename:    "invite.ics",
+			Type:        "application/ics",
+			Disposition: "attachment",
+		})
+	}
+
+	if msg.ReplyTo != "" {
+		replyTo, err := sendGridAddresses([]string{msg.ReplyTo})
+		if err != nil {
+			return nil, err
+		}
+		payload.ReplyTo = &replyTo[0]
+	}
+
+	payload.Headers = map[string]string{"Message-ID": "<" + msg.MessageID + ">"}
+	for field, values := range msg.Headers {
+		payload.Headers[textproto.CanonicalMIMEHeaderKey(field)] = strings.Join(values, ", ")
+	}
+
+	for _, path := range msg.Attachments {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s: %w", path, err)
+		}
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(content),
+			Filename:    filepath.Base(path),
+			Type:        mime.TypeByExtension(filepath.Ext(path)),
+			Disposition: "attachment",
+		})
+	}
+
+	for _, attachment := range msg.readers {
+		var content bytes.Buffer
+		if err := attachment.copyFunc()(&content); err != nil {
+			return nil, err
+		}
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(content.Bytes()),
+			Filename:    attachment.filename,
+			Type:        attachment.contentType,
+			Disposition: "attachment",
+		})
+	}
+
+	for _, inline := range msg.Inline {
+		content, err := os.ReadFile(inline.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inline file %s: %w", inline.Path, err)
+		}
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(content),
+			Filename:    filepath.Base(inline.Path),
+			Type:        mime.TypeByExtension(filepath.Ext(inline.Path)),
+			Disposition: "inline",
+			ContentID:   inline.ContentID,
+		})
+	}
+
+	return payload, nil
+}
+
+func sendGridAddresses(values []string) ([]sendGridAddress, error) {
+	var addresses []sendGridAddress
+	for _, value := range values {
+		addr, err := mail.ParseAddress(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", value, err)
+		}
+		addresses = append(addresses, sendGridAddress{Email: addr.Address, Name: addr.Name})
+	}
+	return addresses, nil
+}
@@ -0,0 +1,142 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type SESConfig struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only needed for temporary credentials.
+	SessionToken string
+	// Endpoint defaults to https://email.<region>.amazonaws.com.
+	Endpoint string
+}
+
+type sesTransport struct {
+	config SESConfig
+}
+
+func newSESTransport(cfg SESConfig) (Transport, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("SES region cannot be empty")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("SES credentials cannot be empty")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://email." + cfg.Region + ".amazonaws.com"
+	}
+	return &sesTransport{config: cfg}, nil
+}
+
+func (t *sesTransport) Name() string {
+	return ProviderSES
+}
+
+// Send delivers the message through the SES v2 API as a raw MIME message, so
+// everything the SMTP transport supports is supported here too.
+func (t *sesTransport) Send(ctx context.Context, msg Message) error {
+	raw, from, to, err := renderMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"FromEmailAddress": from,
+		"Destination":      map[string]any{"ToAddresses": to},
+		"Content": map[string]any{
+			"Raw": map[string]any{"Data": base64.StdEncoding.EncodeToString(raw)},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode SES request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.Endpoint+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SES request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	t.sign(req, body, time.Now().UTC())
+
+	return doAPIRequest(req, ProviderSES)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (t *sesTransport) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if t.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", t.config.SessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-date"}
+	if t.config.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + t.config.Region + "/ses/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+t.config.SecretAccessKey), date)
+	key = hmacSHA256(key, t.config.Region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.config.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
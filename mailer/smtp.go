@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+var (
+	smtpConn     gomail.SendCloser
+	smtpLastUsed time.Time
+	smtpMu       sync.Mutex
+	idleStop     chan struct{}
+)
+
+const (
+	SMTPSecuritySSL      = "ssl"
+	SMTPSecuritySTARTTLS = "starttls"
+)
+
+func newDialer() *gomail.Dialer {
+	dialer := gomail.NewDialer(
+		mailerConfig.SMTPHost,
+		mailerConfig.SMTPPort,
+		mailerConfig.EmailAccount,
+		mailerConfig.EmailPassword,
+	)
+
+	switch mailerConfig.SMTPSecurity {
+	case SMTPSecuritySSL:
+		dialer.SSL = true
+	case SMTPSecuritySTARTTLS:
+		dialer.SSL = false
+	}
+	dialer.TLSConfig = mailerConfig.TLSConfig
+	dialer.LocalName = mailerConfig.LocalName
+
+	return dialer
+}
+
+func smtpTLSConfig(cfg Config) (*tls.Config, error) {
+	switch cfg.SMTPSecurity {
+	case "", SMTPSecuritySSL, SMTPSecuritySTARTTLS:
+	default:
+		return nil, fmt.Errorf("unsupported SMTP security mode: %s", cfg.SMTPSecurity)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.SMTPHost,
+		InsecureSkipVerify: cfg.SMTPInsecureSkipVerify,
+	}
+
+	if cfg.SMTPCACertFile != "" {
+		bundle, err := os.ReadFile(cfg.SMTPCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SMTP CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in SMTP CA bundle %s", cfg.SMTPCACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// sendMessage delivers m over the shared SMTP connection, dialing on demand.
+// Servers drop idle connections without notice, so a failure on a reused
+// connection that was not an SMTP reply is retried once on a fresh one.
+func sendMessage(m *gomail.Message) error {
+	from, to, err := envelope(m)
+	if err != nil {
+		return err
+	}
+
+	content, err := messageContent(m)
+	if err != nil {
+		return err
+	}
+
+	smtpMu.Lock()
+	defer smtpMu.Unlock()
+
+	reused := smtpConn != nil
+	if err := dialLocked(); err != nil {
+		return err
+	}
+
+	err = smtpConn.Send(from, to, content)
+	if err != nil && reused && !isSMTPReply(err) {
+		closeLocked()
+		if err := dialLocked(); err != nil {
+			return err
+		}
+		err = smtpConn.Send(from, to, content)
+	}
+	if err != nil {
+		closeLocked()
+		return err
+	}
+
+	smtpLastUsed = time.Now()
+	return nil
+}
+
+func dialLocked() error {
+	if smtpConn != nil {
+		return nil
+	}
+
+	conn, err := newDialer().Dial()
+	if err != nil {
+		if isSMTPReply(err) {
+			return fmt.Errorf("failed to connect to SMTP server: %w", err)
+		}
+		return fmt.Errorf("%w: failed to connect to SMTP server: %w", ErrConnection, err)
+	}
+	smtpConn = conn
+	return nil
+}
+
+func closeLocked() {
+	if smtpConn == nil {
+		return
+	}
+	smtpConn.Close()
+	smtpConn = nil
+}
+
+// closeIdleConnection closes the shared connection once it has been unused
+// for longer than the configured idle timeout. It checks at most once a
+// second, so a tiny timeout does not turn into a busy loop.
+func closeIdleConnection(timeout time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(max(timeout/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			smtpMu.Lock()
+			if smtpConn != nil && time.Since(smtpLastUsed) > timeout {
+				closeLocked()
+			}
+			smtpMu.Unlock()
+		}
+	}
+}
+
+// Close closes the persistent SMTP connection. It should be called when the
+// application shuts down.
+func Close() {
+	smtpMu.Lock()
+	defer smtpMu.Unlock()
+
+	if idleStop != nil {
+		close(idleStop)
+		idleStop = nil
+	}
+	closeLocked()
+}
+
+func envelope(m *gomail.Message) (string, []string, error) {
+	from := m.GetHeader("Sender")
+	if len(from) == 0 {
+		from = m.GetHeader("From")
+	}
+	if len(from) == 0 {
+		return "", nil, fmt.Errorf("message has no sender")
+	}
+	sender, err := mail.ParseAddress(from[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid sender address %q: %w", from[0], err)
+	}
+
+	var recipients []string
+	seen := map[string]bool{}
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		for _, value := range m.GetHeader(field) {
+			addr, err := mail.ParseAddress(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid recipient address %q: %w", value, err)
+			}
+			if !seen[addr.Address] {
+				seen[addr.Address] = true
+				recipients = append(recipients, addr.Address)
+			}
+		}
+	}
+	if len(recipients) == 0 {
+		return "", nil, fmt.Errorf("message has no recipients")
+	}
+
+	return sender.Address, recipients, nil
+}
+
+func isSMTPReply(err error) bool {
+	_, ok := err.(*textproto.Error)
+	return ok
+}
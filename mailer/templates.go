@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Template is a reusable email whose subject and body are Go templates.
+// HTML bodies are rendered with html/template so recipient data is escaped.
+type Template struct {
+	Subject  string
+	BodyType string
+	Body     string
+}
+
+type parsedTemplate struct {
+	bodyType string
+	subject  *texttemplate.Template
+	text     *texttemplate.Template
+	html     *htmltemplate.Template
+}
+
+var (
+	templates   = map[string]*parsedTemplate{}
+	templatesMu sync.RWMutex
+)
+
+// RegisterTemplate parses tmpl and stores it under name, replacing any
+// template previously registered with that name.
+func RegisterTemplate(name string, tmpl Template) error {
+	if tmpl.BodyType == "" {
+		tmpl.BodyType = "text/html"
+	}
+
+	parsed := &parsedTemplate{bodyType: tmpl.BodyType}
+
+	var err error
+	if parsed.subject, err = texttemplate.New(name + ":subject").Parse(tmpl.Subject); err != nil {
+		return fmt.Errorf("failed to parse subject of template %s: %w", name, err)
+	}
+	if strings.HasPrefix(tmpl.BodyType, "text/html") {
+		parsed.html, err = htmltemplate.New(name).Parse(tmpl.Body)
+	} else {
+		parsed.text, err = texttemplate.New(name).Parse(tmpl.Body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse body of template %s: %w", name, err)
+	}
+
+	templatesMu.Lock()
+	templates[name] = parsed
+	templatesMu.Unlock()
+	return nil
+}
+
+func templateRegistered(name string) bool {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	_, ok := templates[name]
+	return ok
+}
+
+// RenderTemplate renders the named template with data into a message without
+// recipients.
+func RenderTemplate(name string, data any) (Message, error) {
+	templatesMu.RLock()
+	parsed, ok := templates[name]
+	templatesMu.RUnlock()
+	if !ok {
+		return Message{}, fmt.Errorf("template %s not registered", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := parsed.subject.Execute(&subject, data); err != nil {
+		return Message{}, fmt.Errorf("failed to render subject of template %s: %w", name, err)
+	}
+
+	var err error
+	if parsed.html != nil {
+		err = parsed.html.Execute(&body, data)
+	} else {
+		err = parsed.text.Execute(&body, data)
+	}
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to render body of template %s: %w", name, err)
+	}
+
+	return Message{
+		Subject:  subject.String(),
+		BodyType: parsed.bodyType,
+		Body:     body.String(),
+	}, nil
+}
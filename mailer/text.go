@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// HTMLToText converts an HTML body into a readable plain-text alternative.
+// Block elements become line breaks, links keep their target in brackets and
+// scripts and styles are dropped.
+func HTMLToText(body string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+
+	var text strings.Builder
+	var hrefs []string
+	skip := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			result := blankLines.ReplaceAllString(text.String(), "\n\n")
+			return strings.TrimSpace(result)
+
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			content := strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+			if content == "" {
+				continue
+			}
+			current := text.String()
+			if len(current) > 0 && !strings.HasSuffix(current, "\n") && !strings.HasSuffix(current, " ") {
+				text.WriteString(" ")
+			}
+			text.WriteString(content)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				if tokenType == html.StartTagToken {
+					skip++
+				}
+			case "br":
+				text.WriteString("\n")
+			case "li":
+				text.WriteString("\n- ")
+			case "p", "div", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "table", "ul", "ol":
+				text.WriteString("\n\n")
+			case "a":
+				href := ""
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						href = string(value)
+					}
+				}
+				hrefs = append(hrefs, href)
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "table", "ul", "ol":
+				text.WriteString("\n\n")
+			case "td", "th":
+				text.WriteString(" ")
+			case "a":
+				if len(hrefs) == 0 {
+					continue
+				}
+				href := hrefs[len(hrefs)-1]
+				hrefs = hrefs[:len(hrefs)-1]
+				if href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(href, "mailto:") {
+					text.WriteString(" (" + href + ")")
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	TrackingEventOpen  = "open"
+	TrackingEventClick = "click"
+)
+
+// TrackingConfig enables open and click tracking for messages with Track set.
+// The host application serves BaseURL/open (returning a 1x1 image) and
+// BaseURL/click (redirecting to the target) and records the events using
+// ParseTrackingRequest.
+type TrackingConfig struct {
+	BaseURL string
+	// Secret signs the rewritten links so the click endpoint cannot be used as
+	// an open redirect. It must be at least 32 bytes long.
+	Secret string
+}
+
+// minTrackingSecretLength keeps tracking signatures from being guessed.
+const minTrackingSecretLength = 32
+
+func (t *TrackingConfig) validate() error {
+	base, err := url.Parse(t.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return fmt.Errorf("tracking base URL must be an absolute http(s) URL, got %q", t.BaseURL)
+	}
+	if len(t.Secret) < minTrackingSecretLength {
+		return fmt.Errorf("tracking secret must be at least %d bytes long", minTrackingSecretLength)
+	}
+	return nil
+}
+
+type TrackingEvent struct {
+	Type      string
+	MessageID string
+	URL       string
+}
+
+var (
+	trackedLinks = regexp.MustCompile(`(?i)(<a\s[^>]*?href\s*=\s*)(["'])(https?://[^"']+)(["'])`)
+	closingBody  = regexp.MustCompile(`(?i)</body\s*>`)
+)
+
+// applyTracking rewrites the links of an HTML body through the click endpoint
+// and appends the open tracking pixel.
+func applyTracking(body string, messageID string) string {
+	tracking := mailerConfig.Tracking
+
+	body = trackedLinks.ReplaceAllStringFunc(body, func(match string) string {
+		parts := trackedLinks.FindStringSubmatch(match)
+		target := htmlUnescapeAmp(parts[3])
+		link := trackingURL(tracking, TrackingEventClick, messageID, target)
+		return parts[1] + parts[2] + strings.ReplaceAll(link, "&", "&amp;") + parts[4]
+	})
+
+	pixel := `<img src="` + strings.ReplaceAll(trackingURL(tracking, TrackingEventOpen, messageID, ""), "&", "&amp;") +
+		`" width="1" height="1" alt="" style="display:none" />`
+	if loc := closingBody.FindStringIndex(body); loc != nil {
+		return body[:loc[0]] + pixel + body[loc[0]:]
+	}
+	return body + pixel
+}
+
+func trackingURL(tracking *TrackingConfig, event string, messageID string, target string) string {
+	query := url.Values{}
+	query.Set("mid", messageID)
+	if target != "" {
+		query.Set("url", target)
+	}
+	query.Set("sig", trackingSignature(tracking, event, messageID, target))
+	return strings.TrimRight(tracking.BaseURL, "/") + "/" + event + "?" + query.Encode()
+}
+
+func trackingSignature(tracking *TrackingConfig, event string, messageID string, target string) string {
+	mac := hmac.New(sha256.New, []byte(tracking.Secret))
+	mac.Write([]byte(event + "\n" + messageID + "\n" + target))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func htmlUnescapeAmp(value string) string {
+	return strings.ReplaceAll(value, "&amp;", "&")
+}
+
+// ParseTrackingRequest extracts and verifies the tracking event of a request
+// to the open or click endpoint. The event type is taken from the last path
+// segment.
+func ParseTrackingRequest(r *http.Request) (*TrackingEvent, error) {
+	tracking := mailerConfig.Tracking
+	if tracking == nil {
+		return nil, fmt.Errorf("tracking not configured")
+	}
+
+	event := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if event != TrackingEventOpen && event != TrackingEventClick {
+		return nil, fmt.Errorf("unknown tracking event: %s", event)
+	}
+
+	query := r.URL.Query()
+	messageID := query.Get("mid")
+	target := query.Get("url")
+	expected := trackingSignature(tracking, event, messageID, target)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return nil, fmt.Errorf("invalid tracking signature")
+	}
+
+	return &TrackingEvent{Type: event, MessageID: messageID, URL: target}, nil
+}
@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+const (
+	ProviderSMTP     = "smtp"
+	ProviderSendGrid = "sendgrid"
+	ProviderSES      = "ses"
+	ProviderMailgun  = "mailgun"
+	// ProviderSandbox captures messages in an in-memory outbox instead of
+	// sending them. See Outbox.
+	ProviderSandbox = "sandbox"
+)
+
+// Transport delivers a prepared message. The SMTP connection is one
+// implementation; the HTTP APIs of email providers are others.
+type Transport interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// APIError is returned by the HTTP transports when the provider rejects a request.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+var (
+	transports []Transport
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+func newTransport(cfg Config, provider string) (Transport, error) {
+	switch provider {
+	case ProviderSMTP:
+		if cfg.EmailPassword == "" {
+			return nil, fmt.Errorf("email password cannot be empty")
+		}
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP host cannot be empty")
+		}
+		if cfg.SMTPPort == 0 {
+			return nil, fmt.Errorf("SMTP port cannot be zero")
+		}
+		return smtpTransport{}, nil
+	case ProviderSendGrid:
+		return newSendGridTransport(cfg.SendGrid)
+	case ProviderSES:
+		return newSESTransport(cfg.SES)
+	case ProviderMailgun:
+		return newMailgunTransport(cfg.Mailgun)
+	case ProviderSandbox:
+		return sandboxTransport{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported email provider: %s", provider)
+	}
+}
+
+// deliver sends msg through the primary transport, falling back to the
+// failover transports in order when a delivery fails with a transient error.
+// It returns the name of the transport that delivered the message.
+func deliver(ctx context.Context, msg Message) (string, error) {
+	var err error
+	for i, transport := range transports {
+		err = transport.Send(ctx, msg)
+		if err == nil {
+			return transport.Name(), nil
+		}
+		if !isTransientError(err) {
+			return "", newSendError(transport.Name(), err)
+		}
+		if i < len(transports)-1 {
+			logging.Warn("Email transport failed, failing over", "transport", transport.Name(), "error", err)
+		} else {
+			err = newSendError(transport.Name(), err)
+		}
+	}
+	return "", err
+}
+
+type smtpTransport struct{}
+
+func (smtpTransport) Name() string {
+	return ProviderSMTP
+}
+
+func (smtpTransport) Send(ctx context.Context, msg Message) error {
+	return sendMessage(buildMessage(msg))
+}
+
+// renderMessage returns the MIME encoding of msg together with its envelope
+// sender and recipients, for APIs that accept raw messages.
+func renderMessage(msg Message) ([]byte, string, []string, error) {
+	mailer := buildMessage(msg)
+
+	from, to, err := envelope(mailer)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	content, err := messageContent(mailer)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var raw bytes.Buffer
+	if _, err := content.WriteTo(&raw); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to render message: %w", err)
+	}
+
+	return raw.Bytes(), from, to, nil
+}
+
+func doAPIRequest(req *http.Request, provider string) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to call %s API: %w", ErrConnection, provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
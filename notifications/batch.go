@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"golang.org/x/time/rate"
+)
+
+// BatchMessage is one notification of a batch together with its target.
+type BatchMessage struct {
+	Target Target
+	Spec   NotificationSpec
+}
+
+type BatchResult struct {
+	// Index is the position of the message in the batch.
+	Index int
+	// Error is a *MulticastError with the per-token errors when a message
+	// to several tokens failed for some of them.
+	Error error
+}
+
+// BatchReport counts a message as failed unless it reached all of its
+// tokens.
+type BatchReport struct {
+	Sent   int
+	Failed int
+	// InvalidTokens lists the tokens FCM reported as no longer registered.
+	InvalidTokens []string
+	Results       []BatchResult
+}
+
+// SendBatch sends the messages with at most concurrency sends in flight and
+// at most ratePerSecond sends started per second (0 means unlimited), so
+// large campaigns stay within FCM quotas. Failures are recorded in the report
+// rather than aborting the batch; it stops early only when ctx is done.
+func SendBatch(ctx context.Context, messages []BatchMessage, concurrency int, ratePerSecond int) (*BatchReport, error) {
+	if _, err := getMessagingClient(); err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		if err := message.Target.validate(); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		if err := validateSpec(message.Spec); err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+	}
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if ratePerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(ratePerSecond), 1)
+	}
+
+	results := make([]BatchResult, len(messages))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = BatchResult{Index: i, Error: sendToTarget(ctx, messages[i].Target, messages[i].Spec)}
+			}
+		}()
+	}
+
+	var waitErr error
+	for i := range messages {
+		if waitErr = limiter.Wait(ctx); waitErr != nil {
+			for j := i; j < len(messages); j++ {
+				results[j] = BatchResult{Index: j, Error: waitErr}
+			}
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	report := &BatchReport{Results: results}
+	for _, result := range results {
+		if result.Error == nil {
+			report.Sent++
+			continue
+		}
+		report.Failed++
+
+		var multicastErr *MulticastError
+		var tokenErr *InvalidTokenError
+		switch {
+		case errors.As(result.Error, &multicastErr):
+			report.InvalidTokens = append(report.InvalidTokens, multicastErr.Response.InvalidTokens...)
+		case errors.As(result.Error, &tokenErr):
+			report.InvalidTokens = append(report.InvalidTokens, tokenErr.Token)
+		}
+	}
+
+	logging.Info("Notification batch finished", "sent", report.Sent, "failed", report.Failed)
+	return report, waitErr
+}
@@ -2,50 +2,166 @@ package notifications
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"sync"
 
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/messaging"
+	"github.com/delightmichael1/go-libs/logging"
 	"google.golang.org/api/option"
 )
 
-func initializeFirebaseApp() (*messaging.Client, error) {
-	opt := option.WithCredentialsFile("adminsdk.json")
-	config := &firebase.Config{ProjectID: "test-dashboard-65d9c"}
-	app, err := firebase.NewApp(context.Background(), config, opt)
-	if err != nil {
-		log.Println("error initializing firebase app: ", err)
-		return nil, err
+type Config struct {
+	// CredentialsFile is the path to a service account key. CredentialsJSON
+	// holds the key itself and takes precedence when set. With neither set,
+	// Application Default Credentials are used.
+	CredentialsFile string
+	CredentialsJSON []byte
+	ProjectID       string
+	// OnInvalidToken is called with every device token FCM reports as no
+	// longer registered, so the application can delete it. Dry runs do not
+	// trigger it.
+	OnInvalidToken func(token string)
+	// WebPush enables sending to browser subscriptions without Firebase. The
+	// Firebase settings may be left empty when only Web Push or SMS is used.
+	WebPush *WebPushConfig
+	// Twilio enables SendSMS through Twilio. SMSProvider plugs in any other
+	// gateway and takes precedence.
+	Twilio      *TwilioConfig
+	SMSProvider SMSProvider
+	// Retry controls retries of FCM quota errors.
+	Retry RetryConfig
+	// InboxCollection enables the in-app notification inbox stored in this
+	// MongoDB collection. The storage package must be initialized. See
+	// NotifyUser.
+	InboxCollection string
+	// InboxPageSize defaults to 20.
+	InboxPageSize int
+}
+
+var errEmptyNotification = fmt.Errorf("notification has neither content nor data")
+
+var (
+	notificationsConfig Config
+	messagingClient     *messaging.Client
+	clientInit          sync.Once
+	configError         error
+)
+
+// Initialize creates the Firebase messaging client shared by all sends.
+func Initialize(cfg Config) error {
+	clientInit.Do(func() {
+		if cfg.ProjectID == "" && cfg.WebPush == nil && cfg.Twilio == nil && cfg.SMSProvider == nil && cfg.InboxCollection == "" {
+			configError = fmt.Errorf("project ID cannot be empty")
+			return
+		}
+
+		if cfg.SMSProvider != nil {
+			smsProvider = cfg.SMSProvider
+		} else if cfg.Twilio != nil {
+			smsProvider, configError = newTwilioProvider(*cfg.Twilio)
+			if configError != nil {
+				return
+			}
+		}
+
+		if cfg.WebPush != nil {
+			webPushKey, configError = newWebPushKey(cfg.WebPush)
+			if configError != nil {
+				return
+			}
+		}
+
+		if cfg.ProjectID != "" {
+			var opts []option.ClientOption
+			if len(cfg.CredentialsJSON) > 0 {
+				opts = append(opts, option.WithCredentialsJSON(cfg.CredentialsJSON))
+			} else if cfg.CredentialsFile != "" {
+				opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+			}
+
+			opts, configError = retryingHTTPClient(context.Background(), opts)
+			if configError != nil {
+				return
+			}
+
+			app, err := firebase.NewApp(context.Background(), &firebase.Config{ProjectID: cfg.ProjectID}, opts...)
+			if err != nil {
+				logging.Error("Error initializing firebase app", "error", err)
+				configError = err
+				return
+			}
+
+			messagingClient, configError = app.Messaging(context.Background())
+			if configError != nil {
+				logging.Error("Error initializing firebase messaging client", "error", configError)
+				return
+			}
+		}
+
+		if cfg.InboxPageSize <= 0 {
+			cfg.InboxPageSize = 20
+		}
+
+		retryConfig = applyRetryDefaults(cfg.Retry)
+		notificationsConfig = cfg
+		logging.Info("Notifications initialized successfully")
+	})
+	return configError
+}
+
+func getMessagingClient() (*messaging.Client, error) {
+	if configError != nil {
+		return nil, configError
 	}
+	if messagingClient == nil {
+		return nil, fmt.Errorf("firebase messaging not initialized. Call Initialize() with a project ID first")
+	}
+	return messagingClient, nil
+}
+
+func SendNotification(deviceToken, title, body string) error {
+	_, err := Send(context.Background(), deviceToken, NotificationSpec{Title: title, Body: body})
+	return err
+}
 
-	client, err := app.Messaging(context.Background())
+// Send sends the notification to a single device and returns the FCM message ID.
+func Send(ctx context.Context, deviceToken string, spec NotificationSpec) (string, error) {
+	id, err := sendMessage(ctx, spec, func(message *messaging.Message) {
+		message.Token = deviceToken
+	})
 	if err != nil {
-		log.Println("error initializing firebase ##  messaging client: ", err)
-		return nil, err
+		logging.Error("Error sending notification", "token", deviceToken, "error", err)
+		return "", tokenError(deviceToken, err, spec.DryRun)
 	}
 
-	return client, nil
+	return id, nil
 }
 
-func SendNotification(deviceToken, title, body string) error {
-	client, err := initializeFirebaseApp()
+// sendMessage sends the message built from spec to the target set by
+// setTarget, honoring DryRun and retrying transient failures.
+func sendMessage(ctx context.Context, spec NotificationSpec, setTarget func(*messaging.Message)) (string, error) {
+	client, err := getMessagingClient()
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	message := &messaging.Message{
-		Token: deviceToken,
-		Notification: &messaging.Notification{
-			Title: title,
-			Body:  body,
-		},
+	if err := validateSpec(spec); err != nil {
+		return "", err
 	}
 
-	_, err = client.Send(context.Background(), message)
-	if err != nil {
-		log.Printf("Error sending notification: %v %v", err, deviceToken)
-		return err
+	message := buildMessage(spec)
+	setTarget(message)
+
+	send := client.Send
+	if spec.DryRun {
+		send = client.SendDryRun
 	}
 
-	return nil
+	var id string
+	err = withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = send(ctx, message)
+		return err
+	})
+	return id, err
 }
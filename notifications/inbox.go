@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InboxNotification is a notification stored for a user's in-app
+// notification center.
+type InboxNotification struct {
+	ID        string            `bson:"_id" json:"id"`
+	UserID    string            `bson:"userId" json:"userId"`
+	Title     string            `bson:"title,omitempty" json:"title,omitempty"`
+	Body      string            `bson:"body,omitempty" json:"body,omitempty"`
+	ImageURL  string            `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+	Data      map[string]string `bson:"data,omitempty" json:"data,omitempty"`
+	CreatedAt time.Time         `bson:"createdAt" json:"createdAt"`
+	ReadAt    *time.Time        `bson:"readAt,omitempty" json:"readAt,omitempty"`
+}
+
+// InboxPage is one page of a user's notifications, newest first.
+type InboxPage struct {
+	Notifications []InboxNotification `json:"notifications"`
+	Page          int                 `json:"page"`
+	PageSize      int                 `json:"pageSize"`
+	Total         int64               `json:"total"`
+}
+
+func inboxCollection() (string, error) {
+	if notificationsConfig.InboxCollection == "" {
+		return "", fmt.Errorf("notification inbox not configured. Set Config.InboxCollection")
+	}
+	return notificationsConfig.InboxCollection, nil
+}
+
+// NotifyUser stores the notification in the user's inbox and pushes it to
+// the user's devices. The notification stays in the inbox when the push
+// fails; the push error is returned together with the stored notification.
+func NotifyUser(ctx context.Context, userID string, deviceTokens []string, spec NotificationSpec) (*InboxNotification, error) {
+	collection, err := inboxCollection()
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("user ID cannot be empty")
+	}
+	if err := validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	notification := &InboxNotification{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Title:     spec.Title,
+		Body:      spec.Body,
+		ImageURL:  spec.ImageURL,
+		Data:      spec.Data,
+		CreatedAt: time.Now(),
+	}
+	if _, err := storage.InsertData(ctx, collection, notification); err != nil {
+		return nil, fmt.Errorf("failed to store notification: %w", err)
+	}
+
+	if len(deviceTokens) == 0 {
+		return notification, nil
+	}
+
+	// The inbox ID lets the app mark the notification read when it is opened.
+	pushSpec := spec
+	pushSpec.Data = map[string]string{"notificationId": notification.ID}
+	for key, value := range spec.Data {
+		pushSpec.Data[key] = value
+	}
+
+	if len(deviceTokens) == 1 {
+		_, err = Send(ctx, deviceTokens[0], pushSpec)
+	} else {
+		_, err = SendMulticast(ctx, deviceTokens, pushSpec)
+	}
+	if err != nil {
+		logging.Error("Stored notification but failed to push it", "notificationId", notification.ID, "error", err)
+		return notification, err
+	}
+	return notification, nil
+}
+
+// ListNotifications returns a page of the user's notifications, newest
+// first. Pages start at 1.
+func ListNotifications(ctx context.Context, userID string, page int) (*InboxPage, error) {
+	collectionName, err := inboxCollection()
+	if err != nil {
+		return nil, err
+	}
+	collection := storage.GetCollectionRef(ctx, collectionName)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", collectionName)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	pageSize := notificationsConfig.InboxPageSize
+	filter := bson.M{"userId": userID}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find notifications: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	result := &InboxPage{Notifications: []InboxNotification{}, Page: page, PageSize: pageSize, Total: total}
+	if err := cursor.All(ctx, &result.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode notifications: %w", err)
+	}
+	return result, nil
+}
+
+// MarkRead marks one of the user's notifications as read. Marking a read
+// notification again keeps its original read time.
+func MarkRead(ctx context.Context, userID string, notificationID string) error {
+	collection, err := inboxCollection()
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.UpdateOne(ctx, collection,
+		bson.M{"_id": notificationID, "userId": userID, "readAt": nil},
+		bson.M{"readAt": time.Now()},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := storage.CountDocuments(ctx, collection, bson.M{"_id": notificationID, "userId": userID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("notification %s not found", notificationID)
+		}
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and
+// returns how many were updated.
+func MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	collectionName, err := inboxCollection()
+	if err != nil {
+		return 0, err
+	}
+	collection := storage.GetCollectionRef(ctx, collectionName)
+	if collection == nil {
+		return 0, fmt.Errorf("failed to get collection %s", collectionName)
+	}
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"userId": userID, "readAt": nil},
+		bson.M{"$set": bson.M{"readAt": time.Now()}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// UnreadCount returns the number of unread notifications of the user.
+func UnreadCount(ctx context.Context, userID string) (int64, error) {
+	collection, err := inboxCollection()
+	if err != nil {
+		return 0, err
+	}
+	return storage.CountDocuments(ctx, collection, bson.M{"userId": userID, "readAt": nil})
+}
@@ -0,0 +1,248 @@
+package notifications
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/messaging"
+)
+
+// NotificationSpec describes a notification independently of its target.
+// Title, Body and ImageURL form the visible notification and may be left
+// empty to send a data-only message, which the app handles in the
+// background.
+type NotificationSpec struct {
+	Title    string `bson:"title,omitempty" json:"title,omitempty"`
+	Body     string `bson:"body,omitempty" json:"body,omitempty"`
+	ImageURL string `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+	// Data is a custom key/value payload delivered to the app, e.g. a deep
+	// link or a sync trigger.
+	Data map[string]string `bson:"data,omitempty" json:"data,omitempty"`
+
+	// TTL is how long FCM keeps the message for an offline device. Zero
+	// uses the FCM default of four weeks.
+	TTL time.Duration `bson:"ttl,omitempty" json:"ttl,omitempty"`
+	// CollapseKey replaces a pending message with the same key, so an
+	// offline device only receives the latest one.
+	CollapseKey string `bson:"collapseKey,omitempty" json:"collapseKey,omitempty"`
+	// Priority is PriorityHigh for time-sensitive alerts that wake the
+	// device, or PriorityNormal.
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`
+	// Silent sends a background push without a visible notification that
+	// wakes the app to handle Data. Title, Body and ImageURL are ignored.
+	Silent bool `bson:"silent,omitempty" json:"silent,omitempty"`
+
+	// DryRun validates the message with FCM without delivering it.
+	DryRun bool `bson:"dryRun,omitempty" json:"dryRun,omitempty"`
+
+	// Android, APNS and Webpush override the delivery on each platform.
+	Android *AndroidOptions `bson:"android,omitempty" json:"android,omitempty"`
+	APNS    *APNSOptions    `bson:"apns,omitempty" json:"apns,omitempty"`
+	Webpush *WebpushOptions `bson:"webpush,omitempty" json:"webpush,omitempty"`
+}
+
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+
+	AndroidPriorityNormal = PriorityNormal
+	AndroidPriorityHigh   = PriorityHigh
+)
+
+type AndroidOptions struct {
+	// ChannelID is the notification channel the notification is posted to
+	// on Android 8 and later.
+	ChannelID string `bson:"channelId,omitempty" json:"channelId,omitempty"`
+	// Priority is AndroidPriorityNormal or AndroidPriorityHigh.
+	Priority string `bson:"priority,omitempty" json:"priority,omitempty"`
+	// CollapseKey groups messages so only the last one is delivered when
+	// the device comes online.
+	CollapseKey string `bson:"collapseKey,omitempty" json:"collapseKey,omitempty"`
+	Sound       string `bson:"sound,omitempty" json:"sound,omitempty"`
+	Icon        string `bson:"icon,omitempty" json:"icon,omitempty"`
+	Color       string `bson:"color,omitempty" json:"color,omitempty"`
+	ClickAction string `bson:"clickAction,omitempty" json:"clickAction,omitempty"`
+}
+
+type APNSOptions struct {
+	Badge *int   `bson:"badge,omitempty" json:"badge,omitempty"`
+	Sound string `bson:"sound,omitempty" json:"sound,omitempty"`
+	// ContentAvailable wakes the app in the background to process the
+	// message.
+	ContentAvailable bool   `bson:"contentAvailable,omitempty" json:"contentAvailable,omitempty"`
+	MutableContent   bool   `bson:"mutableContent,omitempty" json:"mutableContent,omitempty"`
+	Category         string `bson:"category,omitempty" json:"category,omitempty"`
+	ThreadID         string `bson:"threadId,omitempty" json:"threadId,omitempty"`
+	// Headers are APNs request headers such as apns-priority.
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+}
+
+type WebpushOptions struct {
+	Icon               string `bson:"icon,omitempty" json:"icon,omitempty"`
+	Badge              string `bson:"badge,omitempty" json:"badge,omitempty"`
+	RequireInteraction bool   `bson:"requireInteraction,omitempty" json:"requireInteraction,omitempty"`
+	// Link is opened when the user clicks the notification. It must be an
+	// HTTPS URL.
+	Link string `bson:"link,omitempty" json:"link,omitempty"`
+	// Headers are Web Push protocol headers such as TTL or Urgency.
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// buildMessage returns the FCM message for spec without a target set.
+func buildMessage(spec NotificationSpec) *messaging.Message {
+	message := &messaging.Message{Data: spec.Data}
+	if !spec.Silent && (spec.Title != "" || spec.Body != "" || spec.ImageURL != "") {
+		message.Notification = &messaging.Notification{
+			Title:    spec.Title,
+			Body:     spec.Body,
+			ImageURL: spec.ImageURL,
+		}
+	}
+
+	if spec.Android != nil {
+		message.Android = androidConfig(spec.Android)
+	}
+	if spec.APNS != nil {
+		message.APNS = apnsConfig(spec.APNS)
+	}
+	if spec.Webpush != nil {
+		message.Webpush = webpushConfig(spec.Webpush)
+	}
+	applyDeliveryOptions(message, spec)
+	return message
+}
+
+// applyDeliveryOptions maps the platform independent delivery options of
+// spec onto each platform, without overriding platform specific settings.
+func applyDeliveryOptions(message *messaging.Message, spec NotificationSpec) {
+	if spec.TTL <= 0 && spec.CollapseKey == "" && spec.Priority == "" && !spec.Silent {
+		return
+	}
+
+	if message.Android == nil {
+		message.Android = &messaging.AndroidConfig{}
+	}
+	if message.Android.CollapseKey == "" {
+		message.Android.CollapseKey = spec.CollapseKey
+	}
+	if message.Android.Priority == "" {
+		message.Android.Priority = spec.Priority
+	}
+	if spec.TTL > 0 {
+		ttl := spec.TTL
+		message.Android.TTL = &ttl
+	}
+
+	if message.APNS == nil {
+		message.APNS = &messaging.APNSConfig{}
+	}
+	apnsHeaders := copyHeaders(message.APNS.Headers)
+	if spec.Silent {
+		// Apple requires background pushes to use the low priority.
+		apnsHeaders["apns-push-type"] = "background"
+		apnsHeaders["apns-priority"] = "5"
+		if message.APNS.Payload == nil {
+			message.APNS.Payload = &messaging.APNSPayload{}
+		}
+		if message.APNS.Payload.Aps == nil {
+			message.APNS.Payload.Aps = &messaging.Aps{}
+		}
+		message.APNS.Payload.Aps.ContentAvailable = true
+	} else if spec.Priority != "" && apnsHeaders["apns-priority"] == "" {
+		apnsHeaders["apns-priority"] = map[string]string{PriorityHigh: "10", PriorityNormal: "5"}[spec.Priority]
+	}
+	if spec.TTL > 0 && apnsHeaders["apns-expiration"] == "" {
+		apnsHeaders["apns-expiration"] = strconv.FormatInt(time.Now().Add(spec.TTL).Unix(), 10)
+	}
+	if spec.CollapseKey != "" && apnsHeaders["apns-collapse-id"] == "" {
+		apnsHeaders["apns-collapse-id"] = spec.CollapseKey
+	}
+	message.APNS.Headers = apnsHeaders
+
+	if message.Webpush == nil {
+		message.Webpush = &messaging.WebpushConfig{}
+	}
+	webpushHeaders := copyHeaders(message.Webpush.Headers)
+	if spec.TTL > 0 && webpushHeaders["TTL"] == "" {
+		webpushHeaders["TTL"] = strconv.Itoa(int(spec.TTL.Seconds()))
+	}
+	if spec.Priority != "" && webpushHeaders["Urgency"] == "" {
+		webpushHeaders["Urgency"] = spec.Priority
+	}
+	if spec.CollapseKey != "" && webpushHeaders["Topic"] == "" {
+		webpushHeaders["Topic"] = spec.CollapseKey
+	}
+	message.Webpush.Headers = webpushHeaders
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers)+3)
+	for name, value := range headers {
+		copied[name] = value
+	}
+	return copied
+}
+
+func androidConfig(options *AndroidOptions) *messaging.AndroidConfig {
+	config := &messaging.AndroidConfig{
+		CollapseKey: options.CollapseKey,
+		Priority:    options.Priority,
+	}
+	if options.ChannelID != "" || options.Sound != "" || options.Icon != "" || options.Color != "" || options.ClickAction != "" {
+		config.Notification = &messaging.AndroidNotification{
+			ChannelID:   options.ChannelID,
+			Sound:       options.Sound,
+			Icon:        options.Icon,
+			Color:       options.Color,
+			ClickAction: options.ClickAction,
+		}
+	}
+	return config
+}
+
+func apnsConfig(options *APNSOptions) *messaging.APNSConfig {
+	return &messaging.APNSConfig{
+		Headers: options.Headers,
+		Payload: &messaging.APNSPayload{
+			Aps: &messaging.Aps{
+				Badge:            options.Badge,
+				Sound:            options.Sound,
+				ContentAvailable: options.ContentAvailable,
+				MutableContent:   options.MutableContent,
+				Category:         options.Category,
+				ThreadID:         options.ThreadID,
+			},
+		},
+	}
+}
+
+func webpushConfig(options *WebpushOptions) *messaging.WebpushConfig {
+	config := &messaging.WebpushConfig{Headers: options.Headers}
+	if options.Icon != "" || options.Badge != "" || options.RequireInteraction {
+		config.Notification = &messaging.WebpushNotification{
+			Icon:               options.Icon,
+			Badge:              options.Badge,
+			RequireInteraction: options.RequireInteraction,
+		}
+	}
+	if options.Link != "" {
+		config.FcmOptions = &messaging.WebpushFcmOptions{Link: options.Link}
+	}
+	return config
+}
+
+func validateSpec(spec NotificationSpec) error {
+	if spec.Title == "" && spec.Body == "" && spec.ImageURL == "" && len(spec.Data) == 0 &&
+		!spec.Silent && (spec.APNS == nil || !spec.APNS.ContentAvailable) {
+		return errEmptyNotification
+	}
+	if spec.Priority != "" && spec.Priority != PriorityNormal && spec.Priority != PriorityHigh {
+		return fmt.Errorf("unsupported priority: %s", spec.Priority)
+	}
+	if spec.Android != nil && spec.Android.Priority != "" &&
+		spec.Android.Priority != AndroidPriorityNormal && spec.Android.Priority != AndroidPriorityHigh {
+		return fmt.Errorf("unsupported Android priority: %s", spec.Android.Priority)
+	}
+	return nil
+}
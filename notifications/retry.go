@@ -0,0 +1,170 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"firebase.google.com/go/messaging"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// RetryConfig controls how sends are retried after FCM rejects them for
+// quota exhaustion. A Retry-After delay sent by FCM replaces the computed
+// backoff. Network and server errors are not covered: the Firebase SDK
+// already retries those itself, up to 4 times, and that cannot be changed.
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt. Defaults to 3; 1 disables
+	// retries after quota errors.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// SendError is returned when FCM rejects a message. Retryable reports
+// whether the failure was transient, i.e. sending again later may succeed.
+type SendError struct {
+	Err       error
+	Retryable bool
+	Attempts  int
+}
+
+func (e *SendError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+var retryConfig RetryConfig
+
+func applyRetryDefaults(cfg RetryConfig) RetryConfig {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	return cfg
+}
+
+// isRetryable reports whether an FCM error is transient.
+func isRetryable(err error) bool {
+	return messaging.IsServerUnavailable(err) ||
+		messaging.IsInternal(err) ||
+		messaging.IsMessageRateExceeded(err)
+}
+
+// withRetry calls send until it succeeds, fails with anything other than a
+// quota error or runs out of attempts. Server errors are not retried here
+// since the SDK has already retried them. Failures are returned as a
+// SendError.
+func withRetry(ctx context.Context, send func(ctx context.Context) error) error {
+	retryAfter := new(atomic.Int64)
+	ctx = context.WithValue(ctx, retryAfterKey{}, retryAfter)
+
+	for attempt := 1; ; attempt++ {
+		retryAfter.Store(0)
+		err := send(ctx)
+		if err == nil {
+			return nil
+		}
+
+		retryable := isRetryable(err)
+		if !messaging.IsMessageRateExceeded(err) || attempt >= retryConfig.MaxAttempts {
+			return &SendError{Err: err, Retryable: retryable, Attempts: attempt}
+		}
+
+		delay := retryBackoff(attempt)
+		if serverDelay := time.Duration(retryAfter.Load()); serverDelay > 0 {
+			delay = serverDelay
+		}
+		if !sleepContext(ctx, delay) {
+			return &SendError{Err: err, Retryable: retryable, Attempts: attempt}
+		}
+	}
+}
+
+func retryBackoff(attempt int) time.Duration {
+	backoff := retryConfig.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= retryConfig.MaxBackoff {
+			return retryConfig.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// sleepContext waits for delay and reports false when ctx is done first.
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type retryAfterKey struct{}
+
+// retryAfterTransport records the Retry-After delay of FCM responses in the
+// request context, since the SDK does not expose it on its errors.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if holder, ok := req.Context().Value(retryAfterKey{}).(*atomic.Int64); ok {
+		if delay := parseRetryAfter(resp.Header.Get("Retry-After")); delay > 0 {
+			holder.Store(int64(delay))
+		}
+	}
+	return resp, nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// retryingHTTPClient returns client options for an authenticated HTTP client
+// that records Retry-After delays.
+func retryingHTTPClient(ctx context.Context, opts []option.ClientOption) ([]option.ClientOption, error) {
+	opts = append(opts, option.WithScopes(
+		"https://www.googleapis.com/auth/cloud-platform",
+		"https://www.googleapis.com/auth/firebase.messaging",
+	))
+	client, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create FCM HTTP client: %w", err)
+	}
+	client.Transport = &retryAfterTransport{base: client.Transport}
+	return []option.ClientOption{option.WithHTTPClient(client)}, nil
+}
@@ -0,0 +1,295 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ScheduleStatusPending    = "pending"
+	ScheduleStatusProcessing = "processing"
+	ScheduleStatusFailed     = "failed"
+)
+
+// Target selects who receives a scheduled notification. Exactly one field
+// must be set.
+type Target struct {
+	Token  string   `bson:"token,omitempty" json:"token,omitempty"`
+	Tokens []string `bson:"tokens,omitempty" json:"tokens,omitempty"`
+	Topic  string   `bson:"topic,omitempty" json:"topic,omitempty"`
+	// Condition is an FCM condition expression. See SendToCondition.
+	Condition string `bson:"condition,omitempty" json:"condition,omitempty"`
+}
+
+func (t Target) validate() error {
+	set := 0
+	for _, ok := range []bool{t.Token != "", len(t.Tokens) > 0, t.Topic != "", t.Condition != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one notification target must be set")
+	}
+	return nil
+}
+
+// ScheduledNotification is a notification waiting for its send time.
+// Delivered notifications are removed; failed ones are kept with their error.
+type ScheduledNotification struct {
+	ID         string           `bson:"_id" json:"id"`
+	Target     Target           `bson:"target" json:"target"`
+	Spec       NotificationSpec `bson:"spec" json:"spec"`
+	Status     string           `bson:"status" json:"status"`
+	SendAt     time.Time        `bson:"sendAt" json:"sendAt"`
+	LeaseUntil time.Time        `bson:"leaseUntil,omitempty" json:"leaseUntil,omitempty"`
+	LastError  string           `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt  time.Time        `bson:"createdAt" json:"createdAt"`
+}
+
+type SchedulerConfig struct {
+	// Collection defaults to "scheduled_notifications". The storage package
+	// must be initialized before the scheduler is started.
+	Collection   string
+	PollInterval time.Duration
+	// Lease is how long a dispatcher may hold a notification before it is
+	// handed out again, e.g. after a crash.
+	Lease time.Duration
+}
+
+var (
+	schedulerConfig SchedulerConfig
+	schedulerMu     sync.Mutex
+	schedulerCancel context.CancelFunc
+	schedulerDone   sync.WaitGroup
+)
+
+// StartScheduler starts the background dispatcher that sends scheduled
+// notifications when they are due. Several instances may share a collection.
+func StartScheduler(cfg SchedulerConfig) error {
+	if _, err := getMessagingClient(); err != nil {
+		return err
+	}
+
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+
+	if schedulerCancel != nil {
+		return fmt.Errorf("notification scheduler already started")
+	}
+
+	if cfg.Collection == "" {
+		cfg.Collection = "scheduled_notifications"
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+	if cfg.Lease == 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	schedulerConfig = cfg
+	schedulerCancel = cancel
+
+	schedulerDone.Add(1)
+	go runScheduler(ctx)
+
+	logging.Info("Notification scheduler started")
+	return nil
+}
+
+// StopScheduler stops the dispatcher and waits for in-flight sends to finish.
+func StopScheduler() {
+	schedulerMu.Lock()
+	cancel := schedulerCancel
+	schedulerCancel = nil
+	schedulerMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	schedulerDone.Wait()
+}
+
+// ScheduleNotification stores the notification for delivery to target at
+// sendAt and returns an ID that can be passed to CancelScheduledNotification.
+func ScheduleNotification(target Target, spec NotificationSpec, sendAt time.Time) (string, error) {
+	collection, err := schedulerCollection()
+	if err != nil {
+		return "", err
+	}
+	if err := target.validate(); err != nil {
+		return "", err
+	}
+	if err := validateSpec(spec); err != nil {
+		return "", err
+	}
+
+	scheduled := &ScheduledNotification{
+		ID:        uuid.NewString(),
+		Target:    target,
+		Spec:      spec,
+		Status:    ScheduleStatusPending,
+		SendAt:    sendAt,
+		CreatedAt: time.Now(),
+	}
+	if _, err := storage.InsertData(context.Background(), collection, scheduled); err != nil {
+		return "", fmt.Errorf("failed to schedule notification: %w", err)
+	}
+	return scheduled.ID, nil
+}
+
+// CancelScheduledNotification cancels a notification that has not been picked
+// up for delivery yet.
+func CancelScheduledNotification(ctx context.Context, id string) error {
+	collection, err := schedulerCollection()
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.DeleteOne(ctx, collection, bson.M{"_id": id, "status": ScheduleStatusPending})
+	if err != nil {
+		return fmt.Errorf("failed to cancel notification %s: %w", id, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("notification %s not found or already being delivered", id)
+	}
+	return nil
+}
+
+// schedulerCollection returns the collection set by StartScheduler.
+func schedulerCollection() (string, error) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+	if schedulerConfig.Collection == "" {
+		return "", fmt.Errorf("notification scheduler not started. Call StartScheduler() first")
+	}
+	return schedulerConfig.Collection, nil
+}
+
+func runScheduler(ctx context.Context) {
+	defer schedulerDone.Done()
+
+	ticker := time.NewTicker(schedulerConfig.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for dispatchNextNotification(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchNextNotification sends one due notification and reports whether
+// there was one.
+func dispatchNextNotification(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	scheduled, err := claimNotification(ctx)
+	if err != nil {
+		logging.Error("Failed to claim scheduled notification", "error", err)
+		return false
+	}
+	if scheduled == nil {
+		return false
+	}
+
+	sendErr := sendToTarget(ctx, scheduled.Target, scheduled.Spec)
+	var multicastErr *MulticastError
+	if errors.As(sendErr, &multicastErr) && multicastErr.Response.SuccessCount > 0 {
+		// Retrying would notify the devices that were reached again.
+		logging.Warn("Scheduled notification partially failed", "id", scheduled.ID, "error", sendErr)
+		sendErr = nil
+	}
+
+	// The outcome is recorded even when StopScheduler cancels ctx, and only
+	// while this dispatcher still holds the lease, so a notification that was
+	// claimed again after its lease expired is left to the new claim.
+	recordCtx := context.WithoutCancel(ctx)
+	claimed := bson.M{"_id": scheduled.ID, "status": ScheduleStatusProcessing, "leaseUntil": scheduled.LeaseUntil}
+	if sendErr == nil {
+		if _, err := storage.DeleteOne(recordCtx, schedulerConfig.Collection, claimed); err != nil {
+			logging.Error("Failed to remove sent notification", "id", scheduled.ID, "error", err)
+		}
+		return true
+	}
+
+	logging.Error("Scheduled notification failed", "id", scheduled.ID, "error", sendErr)
+	_, err = storage.UpdateOne(recordCtx, schedulerConfig.Collection, claimed, bson.M{
+		"status":    ScheduleStatusFailed,
+		"lastError": sendErr.Error(),
+	})
+	if err != nil {
+		logging.Error("Failed to update scheduled notification", "id", scheduled.ID, "error", err)
+	}
+	return true
+}
+
+func claimNotification(ctx context.Context) (*ScheduledNotification, error) {
+	collection := storage.GetCollectionRef(ctx, schedulerConfig.Collection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", schedulerConfig.Collection)
+	}
+
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": ScheduleStatusPending, "sendAt": bson.M{"$lte": now}},
+		{"status": ScheduleStatusProcessing, "leaseUntil": bson.M{"$lte": now}},
+	}}
+	update := bson.M{"$set": bson.M{
+		"status":     ScheduleStatusProcessing,
+		"leaseUntil": now.Add(schedulerConfig.Lease),
+	}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"sendAt": 1}).
+		SetReturnDocument(options.After)
+
+	var scheduled ScheduledNotification
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&scheduled); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+// sendToTarget returns a MulticastError when a send to Tokens failed for
+// some of them, since SendMulticast itself only fails as a whole.
+func sendToTarget(ctx context.Context, target Target, spec NotificationSpec) error {
+	var err error
+	switch {
+	case target.Token != "":
+		_, err = Send(ctx, target.Token, spec)
+	case len(target.Tokens) > 0:
+		var resp *MulticastResponse
+		resp, err = SendMulticast(ctx, target.Tokens, spec)
+		if err == nil && resp.FailureCount > 0 {
+			err = &MulticastError{Response: resp}
+		}
+	case target.Topic != "":
+		_, err = SendToTopic(ctx, target.Topic, spec)
+	case target.Condition != "":
+		_, err = SendToCondition(ctx, target.Condition, spec)
+	}
+	return err
+}
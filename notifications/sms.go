@@ -0,0 +1,261 @@
+package notifications
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+const (
+	SMSStatusQueued      = "queued"
+	SMSStatusSent        = "sent"
+	SMSStatusDelivered   = "delivered"
+	SMSStatusUndelivered = "undelivered"
+	SMSStatusFailed      = "failed"
+)
+
+// SMSResult describes an SMS and its delivery state as reported by the
+// provider.
+type SMSResult struct {
+	ID           string
+	Provider     string
+	To           string
+	Status       string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// SMSProvider sends SMS messages. Twilio is built in; other gateways can be
+// plugged in through Config.SMSProvider.
+type SMSProvider interface {
+	Name() string
+	Send(ctx context.Context, to string, body string) (*SMSResult, error)
+	// Status fetches the current delivery state of a sent message.
+	Status(ctx context.Context, id string) (*SMSResult, error)
+}
+
+// SMSError is returned when the SMS provider rejects a request.
+type SMSError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *SMSError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s %s", e.Provider, e.StatusCode, e.Code, e.Message)
+}
+
+var smsProvider SMSProvider
+
+// SendSMS sends body to the phone number to, given in E.164 format.
+func SendSMS(ctx context.Context, to string, body string) (*SMSResult, error) {
+	if smsProvider == nil {
+		return nil, fmt.Errorf("SMS not configured. Set Config.Twilio or Config.SMSProvider")
+	}
+	if to == "" {
+		return nil, fmt.Errorf("SMS recipient cannot be empty")
+	}
+	if body == "" {
+		return nil, fmt.Errorf("SMS body cannot be empty")
+	}
+
+	result, err := smsProvider.Send(ctx, to, body)
+	if err != nil {
+		logging.Error("Error sending SMS", "to", to, "error", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SMSStatus returns the delivery state of a message sent with SendSMS.
+func SMSStatus(ctx context.Context, id string) (*SMSResult, error) {
+	if smsProvider == nil {
+		return nil, fmt.Errorf("SMS not configured. Set Config.Twilio or Config.SMSProvider")
+	}
+	return smsProvider.Status(ctx, id)
+}
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	// From is the sending number. MessagingServiceSID may be set instead to
+	// let Twilio pick the sender.
+	From                string
+	MessagingServiceSID string
+	// StatusCallbackURL receives delivery status updates. Parse them with
+	// ParseTwilioStatusCallback.
+	StatusCallbackURL string
+	// BaseURL defaults to https://api.twilio.com.
+	BaseURL string
+}
+
+type twilioProvider struct {
+	config TwilioConfig
+	client *http.Client
+}
+
+func newTwilioProvider(cfg TwilioConfig) (SMSProvider, error) {
+	if cfg.AccountSID == "" || cfg.AuthToken == "" {
+		return nil, fmt.Errorf("Twilio credentials cannot be empty")
+	}
+	if cfg.From == "" && cfg.MessagingServiceSID == "" {
+		return nil, fmt.Errorf("Twilio sender cannot be empty")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.twilio.com"
+	}
+	return &twilioProvider{config: cfg, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (p *twilioProvider) Name() string {
+	return "twilio"
+}
+
+type twilioMessage struct {
+	SID          string `json:"sid"`
+	To           string `json:"to"`
+	Status       string `json:"status"`
+	ErrorCode    *int   `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+func (p *twilioProvider) Send(ctx context.Context, to string, body string) (*SMSResult, error) {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", body)
+	if p.config.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", p.config.MessagingServiceSID)
+	} else {
+		form.Set("From", p.config.From)
+	}
+	if p.config.StatusCallbackURL != "" {
+		form.Set("StatusCallback", p.config.StatusCallbackURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.messagesURL(".json"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Twilio request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return p.do(req)
+}
+
+func (p *twilioProvider) Status(ctx context.Context, id string) (*SMSResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.messagesURL("/"+url.PathEscape(id)+".json"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Twilio request: %w", err)
+	}
+	return p.do(req)
+}
+
+func (p *twilioProvider) messagesURL(suffix string) string {
+	return p.config.BaseURL + "/2010-04-01/Accounts/" + url.PathEscape(p.config.AccountSID) + "/Messages" + suffix
+}
+
+func (p *twilioProvider) do(req *http.Request) (*SMSResult, error) {
+	req.SetBasicAuth(p.config.AccountSID, p.config.AuthToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Twilio API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Twilio response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return nil, &SMSError{
+			Provider:   p.Name(),
+			StatusCode: resp.StatusCode,
+			Code:       fmt.Sprint(apiErr.Code),
+			Message:    apiErr.Message,
+		}
+	}
+
+	var message twilioMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("failed to decode Twilio response: %w", err)
+	}
+
+	result := &SMSResult{
+		ID:           message.SID,
+		Provider:     p.Name(),
+		To:           message.To,
+		Status:       message.Status,
+		ErrorMessage: message.ErrorMessage,
+	}
+	if message.ErrorCode != nil {
+		result.ErrorCode = fmt.Sprint(*message.ErrorCode)
+	}
+	return result, nil
+}
+
+// ParseTwilioStatusCallback verifies the signature of a Twilio status
+// callback request and returns the reported delivery state.
+func ParseTwilioStatusCallback(r *http.Request) (*SMSResult, error) {
+	provider, ok := smsProvider.(*twilioProvider)
+	if !ok {
+		return nil, fmt.Errorf("Twilio not configured")
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("failed to parse Twilio callback: %w", err)
+	}
+
+	callbackURL := provider.config.StatusCallbackURL
+	if callbackURL == "" {
+		scheme := "https"
+		if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") == "http" {
+			scheme = "http"
+		}
+		callbackURL = scheme + "://" + r.Host + r.URL.RequestURI()
+	}
+
+	// The signature covers the URL followed by the POST parameters sorted
+	// by name, each as name and value concatenated.
+	names := make([]string, 0, len(r.PostForm))
+	for name := range r.PostForm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	signed := callbackURL
+	for _, name := range names {
+		for _, value := range r.PostForm[name] {
+			signed += name + value
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(provider.config.AuthToken))
+	mac.Write([]byte(signed))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Twilio-Signature"))) {
+		return nil, fmt.Errorf("invalid Twilio signature")
+	}
+
+	return &SMSResult{
+		ID:           r.PostForm.Get("MessageSid"),
+		Provider:     provider.Name(),
+		To:           r.PostForm.Get("To"),
+		Status:       r.PostForm.Get("MessageStatus"),
+		ErrorCode:    r.PostForm.Get("ErrorCode"),
+		ErrorMessage: r.PostForm.Get("ErrorMessage"),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Template is a reusable notification whose title and body are Go
+// templates, e.g. "Your order {{.OrderID}} shipped". Locale selects the
+// language variant it provides; an empty Locale is the default variant.
+type Template struct {
+	Locale string
+	Title  string
+	Body   string
+}
+
+type parsedTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+var (
+	templates   = map[string]map[string]*parsedTemplate{}
+	templatesMu sync.RWMutex
+)
+
+// RegisterTemplate parses tmpl and stores it under name and its locale,
+// replacing any variant previously registered for that locale.
+func RegisterTemplate(name string, tmpl Template) error {
+	locale := normalizeLocale(tmpl.Locale)
+	parsed := &parsedTemplate{}
+
+	var err error
+	if parsed.title, err = template.New(name + ":title").Option("missingkey=error").Parse(tmpl.Title); err != nil {
+		return fmt.Errorf("failed to parse title of template %s: %w", name, err)
+	}
+	if parsed.body, err = template.New(name + ":body").Option("missingkey=error").Parse(tmpl.Body); err != nil {
+		return fmt.Errorf("failed to parse body of template %s: %w", name, err)
+	}
+
+	templatesMu.Lock()
+	if templates[name] == nil {
+		templates[name] = map[string]*parsedTemplate{}
+	}
+	templates[name][locale] = parsed
+	templatesMu.Unlock()
+	return nil
+}
+
+// RenderTemplate renders the named template with data. The variant for locale
+// is used when registered, falling back to its base language ("pt" for
+// "pt-BR") and then to the default variant.
+func RenderTemplate(name string, locale string, data any) (NotificationSpec, error) {
+	parsed, err := lookupTemplate(name, locale)
+	if err != nil {
+		return NotificationSpec{}, err
+	}
+
+	var title, body bytes.Buffer
+	if err := parsed.title.Execute(&title, data); err != nil {
+		return NotificationSpec{}, fmt.Errorf("failed to render title of template %s: %w", name, err)
+	}
+	if err := parsed.body.Execute(&body, data); err != nil {
+		return NotificationSpec{}, fmt.Errorf("failed to render body of template %s: %w", name, err)
+	}
+
+	return NotificationSpec{Title: title.String(), Body: body.String()}, nil
+}
+
+// SendFromTemplate renders the default variant of the named template and
+// sends it to a single device.
+func SendFromTemplate(ctx context.Context, deviceToken string, name string, data any) (string, error) {
+	return SendLocalizedTemplate(ctx, deviceToken, name, "", data)
+}
+
+// SendLocalizedTemplate renders the named template for locale and sends it to
+// a single device.
+func SendLocalizedTemplate(ctx context.Context, deviceToken string, name string, locale string, data any) (string, error) {
+	spec, err := RenderTemplate(name, locale, data)
+	if err != nil {
+		return "", err
+	}
+	return Send(ctx, deviceToken, spec)
+}
+
+func lookupTemplate(name string, locale string) (*parsedTemplate, error) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	variants, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not registered", name)
+	}
+
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, "")
+
+	for _, candidate := range candidates {
+		if parsed, ok := variants[candidate]; ok {
+			return parsed, nil
+		}
+	}
+	return nil, fmt.Errorf("template %s has no variant for locale %q and no default", name, locale)
+}
+
+// normalizeLocale lowercases a locale and uses "-" as separator, so "pt_BR"
+// and "pt-br" select the same variant.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
@@ -0,0 +1,171 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"firebase.google.com/go/messaging"
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+// multicastConcurrency is how many single sends SendMulticast keeps in
+// flight. FCM retired its batch endpoint, so each token is sent on its own.
+const multicastConcurrency = 16
+
+// InvalidTokenError is returned when FCM reports that a device token is no
+// longer registered, e.g. because the app was uninstalled, or when
+// ValidateToken finds a token malformed.
+type InvalidTokenError struct {
+	Token string
+	Err   error
+}
+
+func (e *InvalidTokenError) Error() string {
+	return fmt.Sprintf("device token %s is invalid: %v", e.Token, e.Err)
+}
+
+func (e *InvalidTokenError) Unwrap() error {
+	return e.Err
+}
+
+// tokenError converts a send failure for token into an InvalidTokenError
+// when FCM reports the token as unregistered, notifying OnInvalidToken unless
+// the send was a dry run.
+func tokenError(token string, err error, dryRun bool) error {
+	cause := err
+	if sendErr, ok := err.(*SendError); ok {
+		cause = sendErr.Err
+	}
+	if !messaging.IsRegistrationTokenNotRegistered(cause) {
+		return err
+	}
+	if notificationsConfig.OnInvalidToken != nil && !dryRun {
+		notificationsConfig.OnInvalidToken(token)
+	}
+	return &InvalidTokenError{Token: token, Err: cause}
+}
+
+// MulticastResponse reports the outcome of a send to several devices.
+type MulticastResponse struct {
+	SuccessCount int
+	FailureCount int
+	// InvalidTokens lists the tokens FCM reported as no longer registered.
+	InvalidTokens []string
+	// Errors maps each failed token to its error.
+	Errors map[string]error
+}
+
+// MulticastError is returned by sends that fan out to several tokens, such
+// as SendBatch and the scheduler, when some of the tokens failed. Response
+// holds the per-token errors.
+type MulticastError struct {
+	Response *MulticastResponse
+}
+
+func (e *MulticastError) Error() string {
+	return fmt.Sprintf("notification failed for %d of %d tokens", e.Response.FailureCount, e.Response.SuccessCount+e.Response.FailureCount)
+}
+
+func (e *MulticastError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Response.Errors))
+	for _, err := range e.Response.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// SendMulticast sends the notification to several devices, one request per
+// token with a bounded number in flight. Transient failures are retried per
+// token.
+func SendMulticast(ctx context.Context, deviceTokens []string, spec NotificationSpec) (*MulticastResponse, error) {
+	if _, err := getMessagingClient(); err != nil {
+		return nil, err
+	}
+	if err := validateSpec(spec); err != nil {
+		return nil, err
+	}
+	if len(deviceTokens) == 0 {
+		return nil, fmt.Errorf("no tokens provided")
+	}
+
+	errs := make([]error, len(deviceTokens))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(multicastConcurrency, len(deviceTokens)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				_, errs[i] = sendMessage(ctx, spec, func(message *messaging.Message) {
+					message.Token = deviceTokens[i]
+				})
+			}
+		}()
+	}
+
+	sent := 0
+dispatch:
+	for ; sent < len(deviceTokens); sent++ {
+		select {
+		case indexes <- sent:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	result := &MulticastResponse{Errors: map[string]error{}}
+	for i, token := range deviceTokens {
+		err := errs[i]
+		if i >= sent {
+			err = ctx.Err()
+		}
+		if err == nil {
+			result.SuccessCount++
+			continue
+		}
+
+		result.FailureCount++
+		err = tokenError(token, err, spec.DryRun)
+		if _, ok := err.(*InvalidTokenError); ok {
+			result.InvalidTokens = append(result.InvalidTokens, token)
+		}
+		result.Errors[token] = err
+	}
+
+	if result.FailureCount > 0 {
+		logging.Error("Multicast notification had failures", "sent", result.SuccessCount, "failed", result.FailureCount)
+	}
+	if sent < len(deviceTokens) {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// ValidateToken checks a device token with FCM without delivering anything,
+// e.g. when the app registers it. It returns an InvalidTokenError when the
+// token is malformed or not registered. OnInvalidToken is not called.
+func ValidateToken(ctx context.Context, deviceToken string) error {
+	client, err := getMessagingClient()
+	if err != nil {
+		return err
+	}
+	if deviceToken == "" {
+		return &InvalidTokenError{Token: deviceToken, Err: fmt.Errorf("token is empty")}
+	}
+
+	err = withRetry(ctx, func(ctx context.Context) error {
+		_, err := client.SendDryRun(ctx, &messaging.Message{Token: deviceToken})
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+	cause := err.(*SendError).Err
+	if messaging.IsRegistrationTokenNotRegistered(cause) || messaging.IsInvalidArgument(cause) {
+		return &InvalidTokenError{Token: deviceToken, Err: cause}
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"firebase.google.com/go/messaging"
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+// maxTopicTokens is the FCM limit of tokens per topic management request.
+const maxTopicTokens = 1000
+
+// TopicResponse reports the outcome of a topic subscription change.
+type TopicResponse struct {
+	SuccessCount int
+	FailureCount int
+	// FailedTokens maps each failed token to the reason given by FCM.
+	FailedTokens map[string]string
+}
+
+// SubscribeToTopic subscribes the device tokens to topic. Token lists larger
+// than the FCM limit are split into several requests.
+func SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*TopicResponse, error) {
+	client, err := getMessagingClient()
+	if err != nil {
+		return nil, err
+	}
+	return manageTopic(ctx, tokens, topic, client.SubscribeToTopic)
+}
+
+// UnsubscribeFromTopic removes the device tokens from topic.
+func UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*TopicResponse, error) {
+	client, err := getMessagingClient()
+	if err != nil {
+		return nil, err
+	}
+	return manageTopic(ctx, tokens, topic, client.UnsubscribeFromTopic)
+}
+
+func manageTopic(
+	ctx context.Context,
+	tokens []string,
+	topic string,
+	manage func(context.Context, []string, string) (*messaging.TopicManagementResponse, error),
+) (*TopicResponse, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens provided")
+	}
+
+	result := &TopicResponse{FailedTokens: map[string]string{}}
+	for start := 0; start < len(tokens); start += maxTopicTokens {
+		end := min(start+maxTopicTokens, len(tokens))
+		batch := tokens[start:end]
+
+		var resp *messaging.TopicManagementResponse
+		err := withRetry(ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = manage(ctx, batch, topic)
+			return err
+		})
+		if err != nil {
+			logging.Error("Error updating subscriptions to topic", "topic", topic, "error", err)
+			return result, err
+		}
+
+		result.SuccessCount += resp.SuccessCount
+		result.FailureCount += resp.FailureCount
+		for _, info := range resp.Errors {
+			result.FailedTokens[batch[info.Index]] = info.Reason
+		}
+	}
+	return result, nil
+}
+
+// SendToTopic sends the notification to every device subscribed to topic and
+// returns the FCM message ID.
+func SendToTopic(ctx context.Context, topic string, spec NotificationSpec) (string, error) {
+	id, err := sendMessage(ctx, spec, func(message *messaging.Message) {
+		message.Topic = strings.TrimPrefix(topic, "/topics/")
+	})
+	if err != nil {
+		logging.Error("Error sending notification to topic", "topic", topic, "error", err)
+		return "", err
+	}
+	return id, nil
+}
+
+// SendToCondition sends the notification to the devices matching an FCM
+// condition expression such as "'sports' in topics && 'accra' in topics".
+// Conditions may combine up to five topics.
+func SendToCondition(ctx context.Context, condition string, spec NotificationSpec) (string, error) {
+	if strings.TrimSpace(condition) == "" {
+		return "", fmt.Errorf("condition cannot be empty")
+	}
+
+	id, err := sendMessage(ctx, spec, func(message *messaging.Message) {
+		message.Condition = condition
+	})
+	if err != nil {
+		logging.Error("Error sending notification to condition", "condition", condition, "error", err)
+		return "", err
+	}
+	return id, nil
+}
@@ -0,0 +1,274 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// WebPushConfig holds the VAPID key pair identifying the application server
+// to browser push services. Keys are base64url encoded as produced by
+// GenerateVAPIDKeys; the public key is also given to the browser when it
+// subscribes.
+type WebPushConfig struct {
+	PublicKey  string
+	PrivateKey string
+	// Subject is a mailto: or https: URL push services can use to contact
+	// the sender.
+	Subject string
+}
+
+// WebPushSubscription is the PushSubscription a browser returns from
+// pushManager.subscribe, usually posted to the server as JSON.
+type WebPushSubscription struct {
+	Endpoint string `bson:"endpoint" json:"endpoint"`
+	Keys     struct {
+		P256dh string `bson:"p256dh" json:"p256dh"`
+		Auth   string `bson:"auth" json:"auth"`
+	} `bson:"keys" json:"keys"`
+}
+
+const (
+	WebPushUrgencyVeryLow = "very-low"
+	WebPushUrgencyLow     = "low"
+	WebPushUrgencyNormal  = "normal"
+	WebPushUrgencyHigh    = "high"
+)
+
+// WebPushMessage is a payload pushed to a browser subscription. The service
+// worker receives Payload in its push event.
+type WebPushMessage struct {
+	Payload []byte
+	// TTL is how long the push service keeps the message for an offline
+	// browser. Defaults to four weeks.
+	TTL     time.Duration
+	Urgency string
+	// Topic replaces a pending message with the same topic.
+	Topic string
+}
+
+// webPushRecordSize is the record size of the aes128gcm encoding. Payloads
+// are sent in a single record, which limits their size.
+const webPushRecordSize = 4096
+
+var webPushKey *ecdsa.PrivateKey
+
+func newWebPushKey(cfg *WebPushConfig) (*ecdsa.PrivateKey, error) {
+	if cfg.Subject == "" {
+		return nil, fmt.Errorf("VAPID subject cannot be empty")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	public := key.PublicKey().Bytes()
+	if base64.RawURLEncoding.EncodeToString(public) != cfg.PublicKey {
+		return nil, fmt.Errorf("VAPID public key does not match the private key")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+// GenerateVAPIDKeys returns a new base64url encoded VAPID key pair.
+func GenerateVAPIDKeys() (publicKey string, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate VAPID keys: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// SendWebPush encrypts the message for the subscription and delivers it to
+// the browser's push service. An expired subscription is reported as an
+// InvalidTokenError for its endpoint and passed to OnInvalidToken.
+func SendWebPush(ctx context.Context, subscription WebPushSubscription, message WebPushMessage) error {
+	if webPushKey == nil {
+		return fmt.Errorf("web push not configured. Set Config.WebPush")
+	}
+
+	body, err := encryptWebPush(subscription, message.Payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(subscription.Endpoint)
+	if err != nil || endpoint.Scheme != "https" {
+		return fmt.Errorf("invalid subscription endpoint %q", subscription.Endpoint)
+	}
+	token, err := vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	if message.TTL == 0 {
+		message.TTL = 28 * 24 * time.Hour
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create web push request: %w", err)
+	}
+	req.Header.Set("Authorization", "vapid t="+token+", k="+notificationsConfig.WebPush.PublicKey)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(message.TTL.Seconds())))
+	if message.Urgency != "" {
+		req.Header.Set("Urgency", message.Urgency)
+	}
+	if message.Topic != "" {
+		req.Header.Set("Topic", message.Topic)
+	}
+
+	resp, err := webPushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send web push: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	err = fmt.Errorf("push service returned status %d: %s", resp.StatusCode, detail)
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		if notificationsConfig.OnInvalidToken != nil {
+			notificationsConfig.OnInvalidToken(subscription.Endpoint)
+		}
+		return &InvalidTokenError{Token: subscription.Endpoint, Err: err}
+	}
+	return err
+}
+
+var webPushClient = &http.Client{Timeout: 30 * time.Second}
+
+// encryptWebPush encrypts payload for the subscription using the aes128gcm
+// content encoding of RFC 8291.
+func encryptWebPush(subscription WebPushSubscription, payload []byte) ([]byte, error) {
+	if len(payload) > webPushRecordSize-aes.BlockSize-1 {
+		return nil, fmt.Errorf("web push payload exceeds %d bytes", webPushRecordSize-aes.BlockSize-1)
+	}
+
+	userPublic, err := decodeBase64URL(subscription.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(subscription.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription auth secret: %w", err)
+	}
+	userKey, err := ecdh.P256().NewPublicKey(userPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription p256dh key: %w", err)
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate web push key: %w", err)
+	}
+	sharedSecret, err := serverKey.ECDH(userKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive web push secret: %w", err)
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), userPublic...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, authSecret, keyInfo), ikm); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	contentKey := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), contentKey); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and the server public key.
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(webPushRecordSize))
+	body.WriteByte(byte(len(serverPublic)))
+	body.Write(serverPublic)
+	// A single record is terminated by the 0x02 padding delimiter.
+	body.Write(gcm.Seal(nil, nonce, append(append([]byte{}, payload...), 0x02), nil))
+	return body.Bytes(), nil
+}
+
+// vapidToken returns a signed VAPID JWT for the push service at audience.
+func vapidToken(audience string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": notificationsConfig.WebPush.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, webPushKey, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// decodeBase64URL accepts the padded and unpadded forms browsers produce.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
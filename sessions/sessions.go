@@ -0,0 +1,251 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"github.com/delightmichael1/go-libs/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrSessionNotFound is returned for unknown, revoked and expired sessions.
+var ErrSessionNotFound = errors.New("session not found")
+
+type Config struct {
+	// Collection defaults to "sessions".
+	Collection string
+	// TTL is how long a session lives after it is created. Defaults to 30 days.
+	TTL time.Duration
+	// IdleTimeout, when set, expires sessions that were not validated for
+	// that long.
+	IdleTimeout time.Duration
+}
+
+// Metadata describes the client a session was created from.
+type Metadata struct {
+	IP        string `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	Device    string `bson:"device,omitempty" json:"device,omitempty"`
+}
+
+// Session is a server-side session. ID identifies the session when listing
+// and revoking sessions; it is a hash of the session token, so it cannot be
+// used to authenticate.
+type Session struct {
+	ID         string    `bson:"_id" json:"id"`
+	UserID     string    `bson:"userId" json:"userId"`
+	Metadata   Metadata  `bson:"metadata" json:"metadata"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	LastSeenAt time.Time `bson:"lastSeenAt" json:"lastSeenAt"`
+	ExpiresAt  time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+var (
+	sessionsConfig Config
+	configInit     sync.Once
+	configError    error
+	isInitialized  bool
+)
+
+// Initialize configures the sessions package and creates the TTL index that
+// lets MongoDB remove expired sessions. The storage package must be
+// initialized first.
+func Initialize(cfg Config) error {
+	configInit.Do(func() {
+		if cfg.Collection == "" {
+			cfg.Collection = "sessions"
+		}
+		if cfg.TTL == 0 {
+			cfg.TTL = 30 * 24 * time.Hour
+		}
+
+		if err := storage.EnsureTTLIndex(context.Background(), cfg.Collection, "expiresAt", 0); err != nil {
+			configError = fmt.Errorf("failed to create session TTL index: %w", err)
+			return
+		}
+
+		sessionsConfig = cfg
+		isInitialized = true
+		logging.Info("Sessions initialized successfully")
+	})
+	return configError
+}
+
+// Create starts a session for userID and returns it with its token. The
+// token is only returned here and should be handed to the client, e.g. in a
+// cookie.
+func Create(ctx context.Context, userID string, meta Metadata) (*Session, string, error) {
+	if !isInitialized {
+		return nil, "", fmt.Errorf("sessions not initialized. Call Initialize() first")
+	}
+	if userID == "" {
+		return nil, "", fmt.Errorf("user ID cannot be empty")
+	}
+
+	token, err := utils.RandomHexKey(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         sessionID(token),
+		UserID:     userID,
+		Metadata:   meta,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionsConfig.TTL),
+	}
+	if _, err := storage.InsertData(ctx, sessionsConfig.Collection, session); err != nil {
+		return nil, "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, token, nil
+}
+
+// Validate returns the session for token and records the time it was last
+// seen. It returns ErrSessionNotFound when the session does not exist, was
+// revoked or has expired.
+func Validate(ctx context.Context, token string) (*Session, error) {
+	collection, err := sessionsCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	filter := bson.M{"_id": sessionID(token), "expiresAt": bson.M{"$gt": now}}
+	if sessionsConfig.IdleTimeout > 0 {
+		filter["lastSeenAt"] = bson.M{"$gt": now.Add(-sessionsConfig.IdleTimeout)}
+	}
+
+	var session Session
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"lastSeenAt": now}}
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&session); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to validate session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// Revoke ends the session identified by token, e.g. on logout.
+func Revoke(ctx context.Context, token string) error {
+	return revoke(ctx, bson.M{"_id": sessionID(token)})
+}
+
+// RevokeSession ends one of userID's sessions by its ID, as listed by
+// ListActive.
+func RevokeSession(ctx context.Context, userID string, id string) error {
+	return revoke(ctx, bson.M{"_id": id, "userId": userID})
+}
+
+// RevokeOthers ends all of userID's sessions except the one identified by
+// currentToken and returns how many were revoked.
+func RevokeOthers(ctx context.Context, userID string, currentToken string) (int64, error) {
+	return revokeMany(ctx, bson.M{"userId": userID, "_id": bson.M{"$ne": sessionID(currentToken)}})
+}
+
+// RevokeAll ends all of userID's sessions, e.g. after a password reset.
+func RevokeAll(ctx context.Context, userID string) (int64, error) {
+	return revokeMany(ctx, bson.M{"userId": userID})
+}
+
+// ListActive returns userID's unexpired sessions, most recently used first.
+func ListActive(ctx context.Context, userID string) ([]Session, error) {
+	collection, err := sessionsCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"userId": userID, "expiresAt": bson.M{"$gt": time.Now()}}
+	if sessionsConfig.IdleTimeout > 0 {
+		filter["lastSeenAt"] = bson.M{"$gt": time.Now().Add(-sessionsConfig.IdleTimeout)}
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"lastSeenAt": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to decode sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// MetadataFromRequest returns the client IP and user agent of r. The IP is
+// taken from X-Forwarded-For when present, so it must only be used behind a
+// proxy that sets that header.
+func MetadataFromRequest(r *http.Request) Metadata {
+	ip := r.RemoteAddr
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip, _, _ = strings.Cut(forwarded, ",")
+		ip = strings.TrimSpace(ip)
+	} else if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	return Metadata{IP: ip, UserAgent: r.UserAgent()}
+}
+
+func revoke(ctx context.Context, filter bson.M) error {
+	if !isInitialized {
+		return fmt.Errorf("sessions not initialized. Call Initialize() first")
+	}
+
+	result, err := storage.DeleteOne(ctx, sessionsConfig.Collection, filter)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func revokeMany(ctx context.Context, filter bson.M) (int64, error) {
+	if !isInitialized {
+		return 0, fmt.Errorf("sessions not initialized. Call Initialize() first")
+	}
+
+	result, err := storage.DeleteMany(ctx, sessionsConfig.Collection, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
+func sessionsCollection(ctx context.Context) (*mongo.Collection, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("sessions not initialized. Call Initialize() first")
+	}
+	collection := storage.GetCollectionRef(ctx, sessionsConfig.Collection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", sessionsConfig.Collection)
+	}
+	return collection, nil
+}
+
+// sessionID hashes token so that a leaked sessions collection does not leak
+// usable tokens.
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,23 +1,53 @@
 package storage
 
 import (
+	"archive/zip"
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/delightmichael1/go-libs/logging"
 	"github.com/google/uuid"
 	"google.golang.org/api/option"
 )
 
 type FilesConfig struct {
-	BucketName      string
+	BucketName string
+	// CredentialsFile is the path to a service account key. When empty,
+	// Application Default Credentials are used.
 	CredentialsFile string
-	Timeout         time.Duration
+	// ProjectID is only required when buckets are created through EnsureBucket.
+	ProjectID string
+	// Endpoint overrides the storage API endpoint, e.g. for a private gateway.
+	Endpoint string
+	// EmulatorHost points the client at a local Firebase Storage or GCS
+	// emulator (e.g. "localhost:9199"). Credentials are not required in this mode.
+	EmulatorHost string
+	Timeout      time.Duration
+	// PreUploadHook, when set, is called with the file content before every
+	// upload. Returning an error rejects the upload with an *UploadRejectedError.
+	PreUploadHook func(name string, r io.Reader) error
+}
+
+type UploadRejectedError struct {
+	FileName string
+	Reason   error
+}
+
+func (e *UploadRejectedError) Error() string {
+	return fmt.Sprintf("upload of %s rejected: %v", e.FileName, e.Reason)
+}
+
+func (e *UploadRejectedError) Unwrap() error {
+	return e.Reason
 }
 
 var (
@@ -32,18 +62,13 @@ func InitializeFiles(cfg FilesConfig) error {
 			configError = fmt.Errorf("bucket name cannot be empty")
 			return
 		}
-		if cfg.CredentialsFile == "" {
-			configError = fmt.Errorf("credentials file path cannot be empty")
-			return
-		}
-
 		if cfg.Timeout == 0 {
 			cfg.Timeout = 10 * time.Second
 		}
 
 		storageConfig = cfg
 		isInitialized = true
-		log.Println("Storage initialized successfully")
+		logging.Info("Storage initialized successfully")
 	})
 	return configError
 }
@@ -53,19 +78,64 @@ func InitializeStorageClient() (*storage.Client, error) {
 		return nil, fmt.Errorf("storage not initialized. Call Initialize() first")
 	}
 
+	opts := []option.ClientOption{}
+	if storageConfig.EmulatorHost != "" {
+		opts = append(opts,
+			option.WithoutAuthentication(),
+			option.WithEndpoint("http://"+storageConfig.EmulatorHost+"/storage/v1/"),
+		)
+	} else {
+		if storageConfig.CredentialsFile != "" {
+			opts = append(opts, option.WithCredentialsFile(storageConfig.CredentialsFile))
+		}
+		if storageConfig.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(storageConfig.Endpoint))
+		}
+	}
+
 	ctx := context.Background()
-	client, err := storage.NewClient(ctx, option.WithCredentialsFile(storageConfig.CredentialsFile))
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing storage client: %v", err)
 	}
 	return client, nil
 }
 
+func buildFileURL(fileName string, token string) string {
+	host := "https://firebasestorage.googleapis.com"
+	if storageConfig.EmulatorHost != "" {
+		host = "http://" + storageConfig.EmulatorHost
+	}
+	return fmt.Sprintf("%s/v0/b/%s/o/%s?alt=media&token=%s",
+		host, storageConfig.BucketName, fileName, token)
+}
+
+// inspectUpload runs the configured pre-upload hook and rewinds the file so
+// the upload starts from the beginning.
+func inspectUpload(file multipart.File, fileName string) error {
+	if storageConfig.PreUploadHook == nil {
+		return nil
+	}
+
+	if err := storageConfig.PreUploadHook(fileName, file); err != nil {
+		return &UploadRejectedError{FileName: fileName, Reason: err}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind file after inspection: %v", err)
+	}
+	return nil
+}
+
 func UploadFile(file multipart.File, fileName string) (string, string, error) {
 	if !isInitialized {
 		return "", "", fmt.Errorf("storage not initialized. Call Initialize() first")
 	}
 
+	if err := inspectUpload(file, fileName); err != nil {
+		return "", "", err
+	}
+
 	id := uuid.New()
 	newFileName := id.String() + fileName
 
@@ -97,8 +167,7 @@ func UploadFile(file multipart.File, fileName string) (string, string, error) {
 		return "", "", fmt.Errorf("failed to set ACL: %v", err)
 	}
 
-	fileURL := fmt.Sprintf("https://firebasestorage.googleapis.com/v0/b/%s/o/%s?alt=media&token=%s",
-		storageConfig.BucketName, newFileName, id.String())
+	fileURL := buildFileURL(newFileName, id.String())
 
 	return fileURL, newFileName, nil
 }
@@ -108,6 +177,10 @@ func UploadFileWithCustomName(file multipart.File, fileName string) (string, err
 		return "", fmt.Errorf("storage not initialized. Call Initialize() first")
 	}
 
+	if err := inspectUpload(file, fileName); err != nil {
+		return "", err
+	}
+
 	client, err := InitializeStorageClient()
 	if err != nil {
 		return "", err
@@ -137,8 +210,7 @@ func UploadFileWithCustomName(file multipart.File, fileName string) (string, err
 		return "", fmt.Errorf("failed to set ACL: %v", err)
 	}
 
-	fileURL := fmt.Sprintf("https://firebasestorage.googleapis.com/v0/b/%s/o/%s?alt=media&token=%s",
-		storageConfig.BucketName, fileName, id.String())
+	fileURL := buildFileURL(fileName, id.String())
 
 	return fileURL, nil
 }
@@ -229,6 +301,63 @@ func DownloadFileBytes(fileName string) ([]byte, error) {
 	return content, nil
 }
 
+// DownloadToFile streams an object to localPath. The data is written to a
+// temporary file in the same directory and renamed into place once complete,
+// so readers never observe a partially downloaded file.
+func DownloadToFile(ctx context.Context, objectName string, localPath string) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	object := bucket.Object(objectName)
+
+	reader, err := object.NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %v", err)
+	}
+	defer reader.Close()
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tmpName := tmpFile.Name()
+
+	if _, err := io.Copy(tmpFile, reader); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write file content: %v", err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to flush file content: %v", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close temp file: %v", err)
+	}
+
+	if err := os.Rename(tmpName, localPath); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to move file into place: %v", err)
+	}
+
+	return nil
+}
+
 func FileExists(fileName string) (bool, error) {
 	if !isInitialized {
 		return false, fmt.Errorf("storage not initialized. Call Initialize() first")
@@ -281,3 +410,220 @@ func GetFileMetadata(fileName string) (map[string]string, error) {
 
 	return attrs.Metadata, nil
 }
+
+// UpdateFileMetadata sets custom metadata on an existing object. When merge is
+// true the given keys are added to (or overwrite) the existing metadata,
+// otherwise the object's metadata is replaced. The Firebase download token is
+// always kept so previously issued file URLs keep working.
+func UpdateFileMetadata(ctx context.Context, fileName string, metadata map[string]string, merge bool) (map[string]string, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	object := bucket.Object(fileName)
+
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata: %v", err)
+	}
+
+	update := map[string]string{}
+	if !merge {
+		// Keys set to an empty value are removed by the API.
+		for key := range attrs.Metadata {
+			if key != "firebaseStorageDownloadTokens" {
+				update[key] = ""
+			}
+		}
+	}
+	for key, value := range metadata {
+		update[key] = value
+	}
+	// An empty map is sent as null, which would delete all metadata
+	// including the download token.
+	if len(update) == 0 {
+		return attrs.Metadata, nil
+	}
+
+	attrs, err = object.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: update})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update file metadata: %v", err)
+	}
+
+	return attrs.Metadata, nil
+}
+
+type FileAttributes struct {
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+}
+
+// UpdateFileAttributes updates the standard object attributes after upload.
+// Empty fields are left unchanged.
+func UpdateFileAttributes(ctx context.Context, fileName string, fileAttrs FileAttributes) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	update := storage.ObjectAttrsToUpdate{}
+	if fileAttrs.ContentType != "" {
+		update.ContentType = fileAttrs.ContentType
+	}
+	if fileAttrs.CacheControl != "" {
+		update.CacheControl = fileAttrs.CacheControl
+	}
+	if fileAttrs.ContentDisposition != "" {
+		update.ContentDisposition = fileAttrs.ContentDisposition
+	}
+	if fileAttrs.ContentEncoding != "" {
+		update.ContentEncoding = fileAttrs.ContentEncoding
+	}
+	if fileAttrs.ContentLanguage != "" {
+		update.ContentLanguage = fileAttrs.ContentLanguage
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	object := bucket.Object(fileName)
+
+	if _, err := object.Update(ctx, update); err != nil {
+		return fmt.Errorf("failed to update file attributes: %v", err)
+	}
+
+	return nil
+}
+
+// RangeReader streams a byte range of an object. ObjectSize and ContentType
+// describe the whole object so callers can build Content-Range responses.
+type RangeReader struct {
+	Offset      int64
+	Length      int64
+	ObjectSize  int64
+	ContentType string
+
+	reader *storage.Reader
+	client *storage.Client
+}
+
+func (r *RangeReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *RangeReader) Close() error {
+	err := r.reader.Close()
+	r.client.Close()
+	return err
+}
+
+// DownloadRange returns a reader for length bytes of the object starting at
+// offset. A length of -1 reads to the end of the object and a negative offset
+// reads the last -offset bytes. The read is bound to ctx rather than the
+// configured timeout so long media streams are not cut off; the caller must
+// Close the returned reader.
+func DownloadRange(ctx context.Context, fileName string, offset int64, length int64) (*RangeReader, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	object := bucket.Object(fileName)
+
+	reader, err := object.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to download file range: %v", err)
+	}
+
+	return &RangeReader{
+		Offset:      reader.Attrs.StartOffset,
+		Length:      reader.Remain(),
+		ObjectSize:  reader.Attrs.Size,
+		ContentType: reader.Attrs.ContentType,
+		reader:      reader,
+		client:      client,
+	}, nil
+}
+
+// ZipObjects streams the named objects into a zip archive written to w. Each
+// object is copied straight from storage into the archive, so only one chunk
+// is held in memory at a time.
+func ZipObjects(ctx context.Context, fileNames []string, w io.Writer) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	archive := zip.NewWriter(w)
+
+	for _, fileName := range fileNames {
+		reader, err := bucket.Object(fileName).NewReader(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to download file %s: %v", fileName, err)
+		}
+
+		header := &zip.FileHeader{
+			Name:     zipEntryName(fileName),
+			Method:   zip.Deflate,
+			Modified: reader.Attrs.LastModified,
+		}
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			reader.Close()
+			return fmt.Errorf("failed to add %s to archive: %v", fileName, err)
+		}
+
+		if _, err := io.Copy(entry, reader); err != nil {
+			reader.Close()
+			return fmt.Errorf("failed to write %s to archive: %v", fileName, err)
+		}
+		reader.Close()
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %v", err)
+	}
+
+	return nil
+}
+
+// zipEntryName turns an object name into a relative archive path, so names
+// such as "../x" or "/etc/x" cannot escape the extraction directory.
+func zipEntryName(fileName string) string {
+	name := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(fileName, "\\", "/")), "/")
+	if name == "" {
+		return "unnamed"
+	}
+	return name
+}
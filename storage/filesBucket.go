@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	LifecycleDelete                = storage.DeleteAction
+	LifecycleSetStorageClass       = storage.SetStorageClassAction
+	LifecycleAbortIncompleteUpload = storage.AbortIncompleteMPUAction
+)
+
+type CORSRule struct {
+	Origins         []string
+	Methods         []string
+	ResponseHeaders []string
+	MaxAge          time.Duration
+}
+
+// LifecycleRule applies Action to the objects matching all of its
+// conditions. At least one condition must be set; use AllObjects for a rule
+// that applies from age 0.
+type LifecycleRule struct {
+	Action        string
+	StorageClass  string
+	AllObjects    bool
+	AgeInDays     int64
+	CreatedBefore time.Time
+	// NumNewerVersions only applies to buckets with versioning.
+	NumNewerVersions int64
+	MatchesPrefix    []string
+	MatchesSuffix    []string
+}
+
+func (r LifecycleRule) hasCondition() bool {
+	return r.AllObjects || r.AgeInDays > 0 || !r.CreatedBefore.IsZero() || r.NumNewerVersions > 0 ||
+		len(r.MatchesPrefix) > 0 || len(r.MatchesSuffix) > 0
+}
+
+// SetLifecycleRules replaces the lifecycle configuration of the configured bucket.
+// Passing no rules clears the existing configuration.
+func SetLifecycleRules(ctx context.Context, rules []LifecycleRule) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	lifecycle := storage.Lifecycle{}
+	for _, rule := range rules {
+		switch rule.Action {
+		case LifecycleDelete, LifecycleAbortIncompleteUpload:
+		case LifecycleSetStorageClass:
+			if rule.StorageClass == "" {
+				return fmt.Errorf("storage class cannot be empty for %s rule", rule.Action)
+			}
+		default:
+			return fmt.Errorf("unsupported lifecycle action: %s", rule.Action)
+		}
+		if rule.AgeInDays < 0 || rule.NumNewerVersions < 0 {
+			return fmt.Errorf("lifecycle conditions cannot be negative for %s rule", rule.Action)
+		}
+		if !rule.hasCondition() {
+			return fmt.Errorf("%s rule needs at least one condition", rule.Action)
+		}
+
+		lifecycle.Rules = append(lifecycle.Rules, storage.LifecycleRule{
+			Action: storage.LifecycleAction{
+				Type:         rule.Action,
+				StorageClass: rule.StorageClass,
+			},
+			Condition: storage.LifecycleCondition{
+				AllObjects:       rule.AllObjects,
+				AgeInDays:        rule.AgeInDays,
+				CreatedBefore:    rule.CreatedBefore,
+				NumNewerVersions: rule.NumNewerVersions,
+				MatchesPrefix:    rule.MatchesPrefix,
+				MatchesSuffix:    rule.MatchesSuffix,
+			},
+		})
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	if _, err := bucket.Update(ctx, storage.BucketAttrsToUpdate{Lifecycle: &lifecycle}); err != nil {
+		return fmt.Errorf("failed to set lifecycle rules: %v", err)
+	}
+
+	return nil
+}
+
+// EnsureBucket creates the named bucket if it does not exist yet. Existing
+// buckets are left untouched.
+func EnsureBucket(ctx context.Context, name string, location string, storageClass string) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+	if name == "" {
+		return fmt.Errorf("bucket name cannot be empty")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(name)
+	_, err = bucket.Attrs(ctx)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrBucketNotExist) {
+		return fmt.Errorf("failed to check bucket existence: %v", err)
+	}
+
+	if storageConfig.ProjectID == "" {
+		return fmt.Errorf("project ID cannot be empty when creating a bucket")
+	}
+
+	attrs := &storage.BucketAttrs{
+		Location:     location,
+		StorageClass: storageClass,
+	}
+	if err := bucket.Create(ctx, storageConfig.ProjectID, attrs); err != nil {
+		return fmt.Errorf("failed to create bucket %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// SetBucketCORS replaces the CORS configuration of the configured bucket.
+// Passing no rules clears the existing configuration.
+func SetBucketCORS(ctx context.Context, rules []CORSRule) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	cors := []storage.CORS{}
+	for _, rule := range rules {
+		cors = append(cors, storage.CORS{
+			Origins:         rule.Origins,
+			Methods:         rule.Methods,
+			ResponseHeaders: rule.ResponseHeaders,
+			MaxAge:          rule.MaxAge,
+		})
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	if _, err := bucket.Update(ctx, storage.BucketAttrsToUpdate{CORS: cors}); err != nil {
+		return fmt.Errorf("failed to set bucket CORS: %v", err)
+	}
+
+	return nil
+}
+
+// SetUniformBucketLevelAccess toggles uniform bucket-level access on the
+// configured bucket. Note that UploadFile sets object ACLs, which are rejected
+// while uniform access is enabled.
+func SetUniformBucketLevelAccess(ctx context.Context, enabled bool) error {
+	if !isInitialized {
+		return fmt.Errorf("storage not initialized. Call Initialize() first")
+	}
+
+	client, err := InitializeStorageClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, storageConfig.Timeout)
+	defer cancel()
+
+	bucket := client.Bucket(storageConfig.BucketName)
+	update := storage.BucketAttrsToUpdate{
+		UniformBucketLevelAccess: &storage.UniformBucketLevelAccess{Enabled: enabled},
+	}
+	if _, err := bucket.Update(ctx, update); err != nil {
+		return fmt.Errorf("failed to set uniform bucket-level access: %v", err)
+	}
+
+	return nil
+}
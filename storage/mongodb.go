@@ -3,9 +3,9 @@ package storage
 import (
 	"context"
 	"fmt"
-	"log"
 	"sync"
 
+	"github.com/delightmichael1/go-libs/logging"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -44,17 +44,17 @@ func Initialize(cfg Config) error {
 		clientOptions := options.Client().ApplyURI(cfg.URI)
 		mongoClientInstance, configError = mongo.Connect(context.Background(), clientOptions)
 		if configError != nil {
-			log.Printf("Failed to initialize MongoDB client: %v", configError)
+			logging.Error("Failed to initialize MongoDB client", "error", configError)
 			return
 		}
 
 		if pingErr := mongoClientInstance.Ping(context.Background(), nil); pingErr != nil {
-			log.Printf("Failed to ping MongoDB: %v", pingErr)
+			logging.Error("Failed to ping MongoDB", "error", pingErr)
 			configError = pingErr
 			return
 		}
 
-		log.Println("Connected to DB")
+		logging.Info("Connected to DB")
 	})
 	return configError
 }
@@ -98,7 +98,7 @@ func CheckCollectionExists(ctx context.Context, collectionName string) (string,
 func GetCollectionRef(ctx context.Context, collectionName string) *mongo.Collection {
 	client, err := getMongoClient()
 	if err != nil {
-		log.Printf("Failed to get mongo client: %v", err)
+		logging.Error("Failed to get mongo client", "error", err)
 		return nil
 	}
 	db := client.Database(databaseName)
@@ -548,8 +548,8 @@ func CreateTTLIndex(ctx context.Context, collectionName string, fieldName string
 		return fmt.Errorf("failed to create TTL index on %s.%s: %w", collectionName, fieldName, err)
 	}
 
-	log.Printf("TTL index '%s' created successfully on %s.%s (expires after %d seconds)",
-		indexName, collectionName, fieldName, expireAfterSeconds)
+	logging.Info("TTL index created", "index", indexName, "collection", collectionName,
+		"field", fieldName, "expireAfterSeconds", expireAfterSeconds)
 	return nil
 }
 
@@ -578,14 +578,14 @@ func EnsureTTLIndex(ctx context.Context, collectionName string, fieldName string
 			if _, hasField := key[fieldName]; hasField {
 				if expireAfter, ok := index["expireAfterSeconds"].(int32); ok {
 					if expireAfter == expireAfterSeconds {
-						log.Printf("TTL index already exists on %s.%s with correct settings", collectionName, fieldName)
+						logging.Debug("TTL index already exists with correct settings", "collection", collectionName, "field", fieldName)
 						return nil
 					}
 					indexName := index["name"].(string)
 					if _, err := collection.Indexes().DropOne(ctx, indexName); err != nil {
 						return fmt.Errorf("failed to drop existing TTL index: %w", err)
 					}
-					log.Printf("Dropped existing TTL index on %s.%s to recreate with new settings", collectionName, fieldName)
+					logging.Info("Dropped existing TTL index to recreate it with new settings", "collection", collectionName, "field", fieldName)
 				}
 			}
 		}
@@ -2,8 +2,8 @@ package storage
 
 import (
 	"context"
-	"log"
 
+	"github.com/delightmichael1/go-libs/logging"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -19,7 +19,7 @@ func Ensure2dsphereIndex(collection *mongo.Collection) {
 
 	_, err := collection.Indexes().CreateOne(context.TODO(), homeIndexModel)
 	if err != nil {
-		log.Fatalf("Failed to create 2dsphere index: %v", err)
+		logging.Fatal("Failed to create 2dsphere index", "error", err)
 	}
 }
 
@@ -33,7 +33,7 @@ func EnsureJob2dsphereIndex(collection *mongo.Collection) {
 
 	_, err := collection.Indexes().CreateOne(context.TODO(), jobIndexModel)
 	if err != nil {
-		log.Fatalf("Failed to create 2dsphere index: %v", err)
+		logging.Fatal("Failed to create 2dsphere index", "error", err)
 	}
 }
 
@@ -51,6 +51,6 @@ func EnsureNameIndexes(collection *mongo.Collection) {
 
 	_, err := collection.Indexes().CreateMany(context.TODO(), indexes)
 	if err != nil {
-		log.Fatalf("Failed to create name indexes: %v", err)
+		logging.Fatal("Failed to create name indexes", "error", err)
 	}
 }
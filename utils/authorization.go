@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type claimsContextKey struct{}
+
+// HasRole reports whether the token was issued with role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasScope reports whether the token was issued with scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// HasScopes reports whether the token was issued with all of scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !c.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireScopes returns middleware that validates the bearer token of each
+// request with hexKey and rejects it unless the token carries all of scopes.
+// Requests without a valid token get 401, those lacking a scope get 403. The
+// claims of accepted requests are available through ClaimsFromContext.
+func RequireScopes(hexKey string, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				http.Error(w, "missing bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := ValidateToken(token, hexKey)
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			if !claims.HasScopes(scopes...) {
+				http.Error(w, "insufficient scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// ContextWithClaims returns a copy of ctx carrying claims.
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by RequireScopes, if any.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	CipherChaCha20Poly1305  = "chacha20-poly1305"
+	CipherXChaCha20Poly1305 = "xchacha20-poly1305"
+	CipherAES256GCM         = "aes-256-gcm"
+)
+
+// Algorithm identifiers stored in the first byte of a ciphertext envelope.
+var cipherIDs = map[string]byte{
+	CipherChaCha20Poly1305:  1,
+	CipherXChaCha20Poly1305: 2,
+	CipherAES256GCM:         3,
+}
+
+const (
+	EncodingHex       = "hex"
+	EncodingBase64URL = "base64url"
+)
+
+// Prefixes mark ciphertexts in the envelope format and their encoding.
+// Ciphertexts without a prefix are the legacy ChaCha20-Poly1305 format of
+// EncryptData.
+const (
+	hexEnvelopePrefix       = "h."
+	base64URLEnvelopePrefix = "u."
+)
+
+// envelopeCompressed is set in the algorithm byte when the plaintext was
+// compressed before encryption.
+const envelopeCompressed = 0x80
+
+type EncryptOptions struct {
+	// Algorithm defaults to CipherXChaCha20Poly1305, whose 24 byte random
+	// nonces are safe to generate for any number of messages under one key.
+	Algorithm string
+	// Encoding is EncodingHex (default) or EncodingBase64URL, which is about
+	// a third shorter and safe in URLs, headers and cookies.
+	Encoding string
+	// Compress deflates the plaintext before encrypting it. It helps with
+	// large, repetitive payloads. Do not compress plaintexts that mix
+	// secrets with attacker controlled data, since the ciphertext length
+	// then leaks information about the secret.
+	Compress bool
+}
+
+// EncryptDataWith encrypts plaintext with the selected algorithm and a hex
+// encoded 32 byte key. The algorithm is recorded in the ciphertext, so
+// DecryptData decrypts it without being told which one was used.
+func EncryptDataWith(plaintext []byte, hexKey string, opts EncryptOptions) (string, error) {
+	if opts.Algorithm == "" {
+		opts.Algorithm = CipherXChaCha20Poly1305
+	}
+	id, ok := cipherIDs[opts.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unsupported cipher algorithm: %s", opts.Algorithm)
+	}
+
+	key, err := decodeKey(hexKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(id, key)
+	if err != nil {
+		return "", err
+	}
+
+	header := id
+	if opts.Compress {
+		var compressed bytes.Buffer
+		writer, _ := flate.NewWriter(&compressed, flate.BestCompression)
+		if _, err := writer.Write(plaintext); err != nil {
+			return "", err
+		}
+		if err := writer.Close(); err != nil {
+			return "", err
+		}
+		plaintext = compressed.Bytes()
+		header |= envelopeCompressed
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	envelope := append([]byte{header}, nonce...)
+	envelope = aead.Seal(envelope, nonce, plaintext, envelope[:1])
+
+	switch opts.Encoding {
+	case "", EncodingHex:
+		return hexEnvelopePrefix + hex.EncodeToString(envelope), nil
+	case EncodingBase64URL:
+		return base64URLEnvelopePrefix + base64.RawURLEncoding.EncodeToString(envelope), nil
+	default:
+		return "", fmt.Errorf("unsupported encoding: %s", opts.Encoding)
+	}
+}
+
+func isEnvelope(encoded string) bool {
+	return strings.HasPrefix(encoded, hexEnvelopePrefix) || strings.HasPrefix(encoded, base64URLEnvelopePrefix)
+}
+
+// decryptEnvelope decrypts a ciphertext in the envelope format. The header
+// byte is authenticated as additional data.
+func decryptEnvelope(encoded string, key []byte) (string, error) {
+	var envelope []byte
+	var err error
+	if strings.HasPrefix(encoded, base64URLEnvelopePrefix) {
+		envelope, err = base64.RawURLEncoding.DecodeString(strings.TrimPrefix(encoded, base64URLEnvelopePrefix))
+	} else {
+		envelope, err = hex.DecodeString(strings.TrimPrefix(encoded, hexEnvelopePrefix))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid encoding", ErrMalformedCiphertext)
+	}
+	if len(envelope) == 0 {
+		return "", fmt.Errorf("%w: ciphertext too short", ErrMalformedCiphertext)
+	}
+
+	aead, err := newAEAD(envelope[0]&^envelopeCompressed, key)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(envelope) < 1+nonceSize+aead.Overhead() {
+		return "", fmt.Errorf("%w: ciphertext too short", ErrMalformedCiphertext)
+	}
+	nonce, ciphertext := envelope[1:1+nonceSize], envelope[1+nonceSize:]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, envelope[:1])
+	if err != nil {
+		return "", fmt.Errorf("%w: authentication failed", ErrMalformedCiphertext)
+	}
+
+	if envelope[0]&envelopeCompressed != 0 {
+		if plaintext, err = io.ReadAll(flate.NewReader(bytes.NewReader(plaintext))); err != nil {
+			return "", fmt.Errorf("%w: invalid compressed data", ErrMalformedCiphertext)
+		}
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(id byte, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case cipherIDs[CipherChaCha20Poly1305]:
+		return chacha20poly1305.New(key)
+	case cipherIDs[CipherXChaCha20Poly1305]:
+		return chacha20poly1305.NewX(key)
+	case cipherIDs[CipherAES256GCM]:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	default:
+		return nil, fmt.Errorf("%w: unknown cipher algorithm id %d", ErrMalformedCiphertext, id)
+	}
+}
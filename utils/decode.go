@@ -0,0 +1,517 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DecodeHook converts in to type to before the default rules apply, e.g. to
+// parse strings into ObjectIDs. It returns handled=false to leave the value
+// to the default rules.
+type DecodeHook func(in any, to reflect.Type) (out any, handled bool, err error)
+
+type DecodeOptions struct {
+	// TagNames are the struct tags consulted for field names, in order.
+	// Defaults to bson, then json.
+	TagNames []string
+	Hooks    []DecodeHook
+}
+
+// Decode copies in into out, which must be a pointer, matching struct
+// fields and map keys by their bson or json tag names, falling back to a
+// case-insensitive match. Unlike Transcode it works on the values directly,
+// so it is faster and types such as time.Time and primitive.ObjectID
+// survive unchanged. primitive.DateTime and primitive.Timestamp decode into
+// time.Time.
+func Decode(in, out any) error {
+	return DecodeWith(in, out, DecodeOptions{})
+}
+
+// DecodeWith is Decode with custom tag names and hooks.
+func DecodeWith(in, out any, opts DecodeOptions) error {
+	if len(opts.TagNames) == 0 {
+		opts.TagNames = defaultDecodeTags
+	}
+
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", out)
+	}
+
+	d := decoder{opts: opts, tagKey: strings.Join(opts.TagNames, ",")}
+	if err := d.decode(reflect.ValueOf(in), target.Elem()); err != nil {
+		var pathErr *decodeError
+		if errors.As(err, &pathErr) {
+			return fmt.Errorf("%s: %w", pathErr.path(), pathErr.err)
+		}
+		return err
+	}
+	return nil
+}
+
+var (
+	defaultDecodeTags = []string{"bson", "json"}
+	anyMapType        = reflect.TypeOf(map[string]any(nil))
+	documentType      = reflect.TypeOf(primitive.D(nil))
+	timeType          = reflect.TypeOf(time.Time{})
+	dateTimeType      = reflect.TypeOf(primitive.DateTime(0))
+	structInfoCache   sync.Map
+)
+
+// decodeError records where decoding failed. The path is only assembled
+// when an error occurs, which keeps successful decodes allocation-light.
+type decodeError struct {
+	segments []string
+	err      error
+}
+
+func (e *decodeError) Error() string {
+	return e.path() + ": " + e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *decodeError) path() string {
+	var b strings.Builder
+	for i := len(e.segments) - 1; i >= 0; i-- {
+		if i < len(e.segments)-1 && !strings.HasPrefix(e.segments[i], "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(e.segments[i])
+	}
+	if b.Len() == 0 {
+		return "value"
+	}
+	return b.String()
+}
+
+func withSegment(err error, segment string) error {
+	var pathErr *decodeError
+	if errors.As(err, &pathErr) {
+		pathErr.segments = append(pathErr.segments, segment)
+		return pathErr
+	}
+	return &decodeError{segments: []string{segment}, err: err}
+}
+
+type fieldInfo struct {
+	index []int
+	// names holds the tag names in order of preference, then the Go name.
+	names []string
+}
+
+type structInfo struct {
+	fields  []fieldInfo
+	byName  map[string]int
+	byLower map[string]int
+}
+
+type structInfoKey struct {
+	typ  reflect.Type
+	tags string
+}
+
+type decoder struct {
+	opts   DecodeOptions
+	tagKey string
+}
+
+func (d decoder) decode(in reflect.Value, out reflect.Value) error {
+	for in.IsValid() && (in.Kind() == reflect.Interface || in.Kind() == reflect.Pointer) {
+		if in.IsNil() {
+			in = reflect.Value{}
+			break
+		}
+		in = in.Elem()
+	}
+	if !in.IsValid() {
+		out.SetZero()
+		return nil
+	}
+
+	for _, hook := range d.opts.Hooks {
+		converted, handled, err := hook(in.Interface(), out.Type())
+		if err != nil {
+			return err
+		}
+		if handled {
+			if converted == nil {
+				out.SetZero()
+				return nil
+			}
+			in = reflect.ValueOf(converted)
+			break
+		}
+	}
+
+	if in.Type().AssignableTo(out.Type()) {
+		out.Set(in)
+		return nil
+	}
+	if converted, ok := convertBSON(in, out.Type()); ok {
+		out.Set(converted)
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(out.Type().Elem())
+		if err := d.decode(in, elem.Elem()); err != nil {
+			return err
+		}
+		out.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		if d.isDocument(in) {
+			return d.decodeStruct(in, out)
+		}
+
+	case reflect.Map:
+		if d.isDocument(in) && out.Type().Key().Kind() == reflect.String {
+			return d.decodeMap(in, out)
+		}
+
+	case reflect.Slice:
+		if in.Kind() != reflect.Slice && in.Kind() != reflect.Array {
+			break
+		}
+		if in.Kind() == reflect.Slice && in.IsNil() {
+			out.SetZero()
+			return nil
+		}
+		result := reflect.MakeSlice(out.Type(), in.Len(), in.Len())
+		for i := 0; i < in.Len(); i++ {
+			if err := d.decode(in.Index(i), result.Index(i)); err != nil {
+				return withSegment(err, fmt.Sprintf("[%d]", i))
+			}
+		}
+		out.Set(result)
+		return nil
+
+	case reflect.Array:
+		if (in.Kind() != reflect.Slice && in.Kind() != reflect.Array) || in.Len() != out.Len() {
+			break
+		}
+		for i := 0; i < in.Len(); i++ {
+			if err := d.decode(in.Index(i), out.Index(i)); err != nil {
+				return withSegment(err, fmt.Sprintf("[%d]", i))
+			}
+		}
+		return nil
+
+	case reflect.String, reflect.Bool:
+		if in.Kind() == out.Kind() {
+			out.Set(in.Convert(out.Type()))
+			return nil
+		}
+
+	default:
+		if isNumber(in.Kind()) && isNumber(out.Kind()) {
+			if !setNumber(in, out) {
+				return fmt.Errorf("%v overflows %s", in.Interface(), out.Type())
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot decode %s into %s", in.Type(), out.Type())
+}
+
+// convertBSON handles the Mongo types that stand in for Go types in
+// decoded documents, such as primitive.DateTime for time.Time. Arrays
+// (primitive.A) are slices and need no conversion.
+func convertBSON(in reflect.Value, to reflect.Type) (reflect.Value, bool) {
+	switch to {
+	case timeType:
+		switch v := in.Interface().(type) {
+		case primitive.DateTime:
+			return reflect.ValueOf(v.Time()), true
+		case primitive.Timestamp:
+			return reflect.ValueOf(time.Unix(int64(v.T), 0)), true
+		}
+	case dateTimeType:
+		if t, ok := in.Interface().(time.Time); ok {
+			return reflect.ValueOf(primitive.NewDateTimeFromTime(t)), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// isDocument reports whether in has named fields: a struct, a map with
+// string keys or a primitive.D.
+func (d decoder) isDocument(in reflect.Value) bool {
+	switch in.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Map:
+		return in.Type().Key().Kind() == reflect.String
+	}
+	return in.Type() == documentType
+}
+
+func (d decoder) decodeStruct(in reflect.Value, out reflect.Value) error {
+	info := d.structInfo(out.Type())
+	lookup := d.lookupFunc(in)
+
+	for _, field := range info.fields {
+		value, ok := lookup(field.names)
+		if !ok {
+			continue
+		}
+		if err := d.decode(value, out.FieldByIndex(field.index)); err != nil {
+			return withSegment(err, field.names[0])
+		}
+	}
+	return nil
+}
+
+func (d decoder) decodeMap(in reflect.Value, out reflect.Value) error {
+	keyType, elemType := out.Type().Key(), out.Type().Elem()
+	result := reflect.MakeMap(out.Type())
+
+	set := func(key string, value reflect.Value) error {
+		elem := reflect.New(elemType).Elem()
+		if err := d.decode(value, elem); err != nil {
+			return withSegment(err, key)
+		}
+		result.SetMapIndex(reflect.ValueOf(key).Convert(keyType), elem)
+		return nil
+	}
+
+	switch {
+	case in.Type() == documentType:
+		for _, elem := range in.Interface().(primitive.D) {
+			if err := set(elem.Key, reflect.ValueOf(elem.Value)); err != nil {
+				return err
+			}
+		}
+	case in.Kind() == reflect.Map:
+		iter := in.MapRange()
+		for iter.Next() {
+			if err := set(iter.Key().String(), iter.Value()); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, field := range d.structInfo(in.Type()).fields {
+			if err := set(field.names[0], in.FieldByIndex(field.index)); err != nil {
+				return err
+			}
+		}
+	}
+
+	out.Set(result)
+	return nil
+}
+
+// lookupFunc returns a function finding the first of names in the document
+// in, trying exact matches before case-insensitive ones.
+func (d decoder) lookupFunc(in reflect.Value) func(names []string) (reflect.Value, bool) {
+	switch {
+	case in.Type() == documentType:
+		doc := in.Interface().(primitive.D)
+		return func(names []string) (reflect.Value, bool) {
+			for _, name := range names {
+				for _, elem := range doc {
+					if elem.Key == name {
+						return reflect.ValueOf(elem.Value), true
+					}
+				}
+			}
+			for _, name := range names {
+				for _, elem := range doc {
+					if strings.EqualFold(elem.Key, name) {
+						return reflect.ValueOf(elem.Value), true
+					}
+				}
+			}
+			return reflect.Value{}, false
+		}
+
+	case in.Kind() == reflect.Map && in.Type().ConvertibleTo(anyMapType):
+		m := in.Convert(anyMapType).Interface().(map[string]any)
+		return func(names []string) (reflect.Value, bool) {
+			for _, name := range names {
+				if value, ok := m[name]; ok {
+					return reflect.ValueOf(value), true
+				}
+			}
+			for key, value := range m {
+				for _, name := range names {
+					if strings.EqualFold(key, name) {
+						return reflect.ValueOf(value), true
+					}
+				}
+			}
+			return reflect.Value{}, false
+		}
+
+	case in.Kind() == reflect.Map:
+		return func(names []string) (reflect.Value, bool) {
+			for _, name := range names {
+				if value := in.MapIndex(reflect.ValueOf(name).Convert(in.Type().Key())); value.IsValid() {
+					return value, true
+				}
+			}
+			iter := in.MapRange()
+			for iter.Next() {
+				for _, name := range names {
+					if strings.EqualFold(iter.Key().String(), name) {
+						return iter.Value(), true
+					}
+				}
+			}
+			return reflect.Value{}, false
+		}
+
+	default:
+		info := d.structInfo(in.Type())
+		return func(names []string) (reflect.Value, bool) {
+			for _, name := range names {
+				if i, ok := info.byName[name]; ok {
+					return in.FieldByIndex(info.fields[i].index), true
+				}
+			}
+			for _, name := range names {
+				if i, ok := info.byLower[strings.ToLower(name)]; ok {
+					return in.FieldByIndex(info.fields[i].index), true
+				}
+			}
+			return reflect.Value{}, false
+		}
+	}
+}
+
+// structInfo returns the decodable fields of t, cached per type and tags.
+func (d decoder) structInfo(t reflect.Type) *structInfo {
+	key := structInfoKey{typ: t, tags: d.tagKey}
+	if cached, ok := structInfoCache.Load(key); ok {
+		return cached.(*structInfo)
+	}
+
+	info := &structInfo{byName: map[string]int{}, byLower: map[string]int{}}
+	d.collectFields(t, nil, info)
+	for i, field := range info.fields {
+		for _, name := range field.names {
+			if _, exists := info.byName[name]; !exists {
+				info.byName[name] = i
+			}
+			if _, exists := info.byLower[strings.ToLower(name)]; !exists {
+				info.byLower[strings.ToLower(name)] = i
+			}
+		}
+	}
+
+	cached, _ := structInfoCache.LoadOrStore(key, info)
+	return cached.(*structInfo)
+}
+
+// collectFields adds the exported fields of t to info. Untagged embedded
+// structs are flattened as in encoding/json.
+func (d decoder) collectFields(t reflect.Type, index []int, info *structInfo) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		var names []string
+		skip := false
+		for _, tagName := range d.opts.TagNames {
+			name, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
+			if name == "-" {
+				skip = true
+				break
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && len(names) == 0 && field.Type.Kind() == reflect.Struct {
+			d.collectFields(field.Type, fieldIndex, info)
+			continue
+		}
+
+		info.fields = append(info.fields, fieldInfo{index: fieldIndex, names: append(names, field.Name)})
+	}
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// setNumber converts the number in to the type of out and reports whether
+// it fits without overflow or loss of a fractional part.
+func setNumber(in reflect.Value, out reflect.Value) bool {
+	switch {
+	case in.CanInt():
+		i := in.Int()
+		switch {
+		case out.CanInt():
+			if out.OverflowInt(i) {
+				return false
+			}
+			out.SetInt(i)
+		case out.CanUint():
+			if i < 0 || out.OverflowUint(uint64(i)) {
+				return false
+			}
+			out.SetUint(uint64(i))
+		default:
+			out.SetFloat(float64(i))
+		}
+
+	case in.CanUint():
+		u := in.Uint()
+		switch {
+		case out.CanInt():
+			if u > math.MaxInt64 || out.OverflowInt(int64(u)) {
+				return false
+			}
+			out.SetInt(int64(u))
+		case out.CanUint():
+			if out.OverflowUint(u) {
+				return false
+			}
+			out.SetUint(u)
+		default:
+			out.SetFloat(float64(u))
+		}
+
+	default:
+		f := in.Float()
+		switch {
+		case out.CanInt():
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || out.OverflowInt(int64(f)) {
+				return false
+			}
+			out.SetInt(int64(f))
+		case out.CanUint():
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || out.OverflowUint(uint64(f)) {
+				return false
+			}
+			out.SetUint(uint64(f))
+		default:
+			if out.OverflowFloat(f) {
+				return false
+			}
+			out.SetFloat(f)
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type decodeOrder struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Customer  string             `bson:"customer" json:"customer"`
+	Total     float64            `bson:"total" json:"total"`
+	Items     []string           `bson:"items" json:"items"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func decodeOrderDocument() bson.M {
+	return bson.M{
+		"_id":       primitive.NewObjectID(),
+		"customer":  "Ada",
+		"total":     int32(42),
+		"items":     primitive.A{"book", "pen"},
+		"createdAt": primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		"updatedAt": primitive.Timestamp{T: 1714564800},
+	}
+}
+
+func TestDecodeBSONTypes(t *testing.T) {
+	doc := decodeOrderDocument()
+
+	var order decodeOrder
+	if err := Decode(doc, &order); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if order.ID != doc["_id"] || order.Customer != "Ada" || order.Total != 42 {
+		t.Errorf("unexpected order %+v", order)
+	}
+	if len(order.Items) != 2 || order.Items[1] != "pen" {
+		t.Errorf("items = %v", order.Items)
+	}
+	if !order.CreatedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("createdAt = %v", order.CreatedAt)
+	}
+	if order.UpdatedAt.Unix() != 1714564800 {
+		t.Errorf("updatedAt = %v", order.UpdatedAt)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	doc := decodeOrderDocument()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var order decodeOrder
+		if err := Decode(doc, &order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranscode(b *testing.B) {
+	doc := decodeOrderDocument()
+	// Transcode cannot turn a primitive.Timestamp into a time.Time.
+	delete(doc, "updatedAt")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var order decodeOrder
+		if err := Transcode(doc, &order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ColumnSpec describes one column of an export.
+type ColumnSpec struct {
+	// Field is the document field, with dots for nested fields, e.g.
+	// "customer.name".
+	Field string
+	// Header defaults to Field.
+	Header string
+	// TimeLayout formats dates in CSV exports. Defaults to time.RFC3339.
+	// XLSX exports store dates as Excel dates.
+	TimeLayout string
+	// NumberFormat is a fmt verb applied to numbers as float64 in CSV
+	// exports, e.g. "%.2f".
+	// XLSX exports store numbers as numbers.
+	NumberFormat string
+	// Format, when set, renders the value instead, e.g. for currencies or
+	// lookups. XLSX exports store the result as text.
+	Format func(value any) string
+}
+
+func (c ColumnSpec) header() string {
+	if c.Header != "" {
+		return c.Header
+	}
+	return c.Field
+}
+
+// RowsFromDocuments converts the documents returned by
+// storage.FindDataNoPagination and storage.FindData into rows for ExportCSV
+// and ExportXLSX.
+func RowsFromDocuments(documents []any) ([]bson.M, error) {
+	rows := make([]bson.M, 0, len(documents))
+	for i, document := range documents {
+		switch doc := document.(type) {
+		case bson.M:
+			rows = append(rows, doc)
+		case bson.D:
+			rows = append(rows, doc.Map())
+		default:
+			data, err := bson.Marshal(document)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert document %d: %w", i, err)
+			}
+			var row bson.M
+			if err := bson.Unmarshal(data, &row); err != nil {
+				return nil, fmt.Errorf("failed to convert document %d: %w", i, err)
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// ExportCSV writes rows to w as CSV with a header line. With no columns,
+// every top-level field of the first row is exported in alphabetical order.
+// Text starting with =, +, -, @ or a control character is prefixed with a
+// quote so spreadsheet applications do not evaluate it as a formula.
+func ExportCSV(w io.Writer, rows []bson.M, columns []ColumnSpec) error {
+	columns = exportColumns(rows, columns)
+
+	writer := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.header()
+	}
+	if err := writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = csvValue(column, lookupField(row, column.Field))
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// ExportXLSX writes rows to w as an Excel workbook with a single sheet and
+// a header row. Columns are chosen as in ExportCSV. The sheet is streamed,
+// so large exports are not buffered in memory.
+func ExportXLSX(w io.Writer, rows []bson.M, columns []ColumnSpec) error {
+	columns = exportColumns(rows, columns)
+
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		if err := writeZipEntry(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	entry, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return fmt.Errorf("failed to write XLSX sheet: %w", err)
+	}
+	sheet := bufio.NewWriter(entry)
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	refs := make([]string, len(columns))
+	for i := range columns {
+		refs[i] = xlsxColumnName(i)
+	}
+
+	sheet.WriteString(`<row r="1">`)
+	for i, column := range columns {
+		writeXLSXString(sheet, refs[i]+"1", xlsxStyleHeader, column.header())
+	}
+	sheet.WriteString(`</row>`)
+
+	for n, row := range rows {
+		rowNumber := strconv.Itoa(n + 2)
+		sheet.WriteString(`<row r="` + rowNumber + `">`)
+		for i, column := range columns {
+			writeXLSXCell(sheet, refs[i]+rowNumber, column, lookupField(row, column.Field))
+		}
+		sheet.WriteString(`</row>`)
+	}
+
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if err := sheet.Flush(); err != nil {
+		return fmt.Errorf("failed to write XLSX sheet: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write XLSX: %w", err)
+	}
+	return nil
+}
+
+func exportColumns(rows []bson.M, columns []ColumnSpec) []ColumnSpec {
+	if len(columns) > 0 || len(rows) == 0 {
+		return columns
+	}
+
+	fields := make([]string, 0, len(rows[0]))
+	for field := range rows[0] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	columns = make([]ColumnSpec, len(fields))
+	for i, field := range fields {
+		columns[i] = ColumnSpec{Field: field}
+	}
+	return columns
+}
+
+// lookupField resolves a dotted path in a document, returning nil when any
+// part of it is missing.
+func lookupField(row bson.M, path string) any {
+	var value any = row
+	for _, key := range strings.Split(path, ".") {
+		switch doc := value.(type) {
+		case bson.M:
+			value = doc[key]
+		case map[string]any:
+			value = doc[key]
+		case bson.D:
+			value = nil
+			for _, element := range doc {
+				if element.Key == key {
+					value = element.Value
+					break
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// exportTime returns the value as a time when it is a date.
+func exportTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case primitive.DateTime:
+		return v.Time().UTC(), true
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// exportNumber returns the value as a float when it is a number.
+func exportNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	case bool:
+		return 0, false
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// exportText renders values that are neither dates nor numbers.
+func exportText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Binary:
+		return fmt.Sprintf("%x", v.Data)
+	case bson.A:
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = exportText(element)
+		}
+		return strings.Join(parts, ", ")
+	case bson.M, bson.D, map[string]any:
+		data, err := bson.MarshalExtJSON(v, false, false)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if t, ok := exportTime(value); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+func csvValue(column ColumnSpec, value any) string {
+	if column.Format != nil {
+		return csvSafe(column.Format(value))
+	}
+	if t, ok := exportTime(value); ok {
+		layout := column.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return t.Format(layout)
+	}
+	if number, ok := exportNumber(value); ok {
+		if column.NumberFormat != "" {
+			return fmt.Sprintf(column.NumberFormat, number)
+		}
+		return exportText(value)
+	}
+	return csvSafe(exportText(value))
+}
+
+// csvSafe neutralizes text that spreadsheet applications would run as a
+// formula.
+func csvSafe(text string) string {
+	if text == "" {
+		return text
+	}
+	switch text[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + text
+	}
+	return text
+}
+
+// xlsxEpoch is day zero of Excel's 1900 date system, accounting for its
+// fictitious 29 February 1900.
+var xlsxEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// Style indexes in xlsxStyles.
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleDate    = 2
+)
+
+func writeXLSXCell(w *bufio.Writer, ref string, column ColumnSpec, value any) {
+	if column.Format != nil {
+		writeXLSXString(w, ref, xlsxStyleDefault, column.Format(value))
+		return
+	}
+	if t, ok := exportTime(value); ok {
+		wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		serial := wall.Sub(xlsxEpoch).Hours() / 24
+		w.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(xlsxStyleDate) + `"><v>` + strconv.FormatFloat(serial, 'f', -1, 64) + `</v></c>`)
+		return
+	}
+	if number, ok := exportNumber(value); ok && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		w.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(number, 'g', -1, 64) + `</v></c>`)
+		return
+	}
+	if b, ok := value.(bool); ok {
+		v := "0"
+		if b {
+			v = "1"
+		}
+		w.WriteString(`<c r="` + ref + `" t="b"><v>` + v + `</v></c>`)
+		return
+	}
+	if value == nil {
+		return
+	}
+	writeXLSXString(w, ref, xlsxStyleDefault, exportText(value))
+}
+
+func writeXLSXString(w *bufio.Writer, ref string, style int, text string) {
+	w.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(style) + `" t="inlineStr"><is><t xml:space="preserve">`)
+	xml.EscapeText(w, []byte(text))
+	w.WriteString(`</t></is></c>`)
+}
+
+// xlsxColumnName returns the column letters for a zero-based index, e.g.
+// 0 is "A" and 27 is "AB".
+func xlsxColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+func writeZipEntry(archive *zip.Writer, name string, content string) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to write XLSX part %s: %w", name, err)
+	}
+	if _, err := io.WriteString(entry, content); err != nil {
+		return fmt.Errorf("failed to write XLSX part %s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxParts are the fixed parts of a single-sheet workbook.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="3">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`</cellXfs>` +
+		`</styleSheet>`},
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	HMACSHA256 = "sha256"
+	HMACSHA512 = "sha512"
+)
+
+// SignHMAC returns the hex encoded HMAC-SHA256 of data, e.g. for signing
+// webhook payloads or URL parameters.
+func SignHMAC(data []byte, key []byte) string {
+	return hex.EncodeToString(hmacSHA256(key, data))
+}
+
+// VerifyHMAC reports whether signature is the hex encoded HMAC-SHA256 of
+// data. The comparison takes constant time.
+func VerifyHMAC(data []byte, signature string, key []byte) bool {
+	expected, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, hmacSHA256(key, data))
+}
+
+// SignHMACWith is SignHMAC with a selectable hash, HMACSHA256 or HMACSHA512.
+func SignHMACWith(algorithm string, data []byte, key []byte) (string, error) {
+	newHash, err := hmacHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyHMACWith is VerifyHMAC with a selectable hash, HMACSHA256 or
+// HMACSHA512.
+func VerifyHMACWith(algorithm string, data []byte, signature string, key []byte) (bool, error) {
+	newHash, err := hmacHash(algorithm)
+	if err != nil {
+		return false, err
+	}
+	expected, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return false, nil
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return hmac.Equal(expected, mac.Sum(nil)), nil
+}
+
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case HMACSHA256:
+		return sha256.New, nil
+	case HMACSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported HMAC algorithm: %s", algorithm)
+	}
+}
@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+)
+
+// JWTConfig holds the keys and expectations for standard JSON Web Tokens.
+type JWTConfig struct {
+	// Algorithm is JWTAlgHS256 or JWTAlgRS256. Tokens signed with any other
+	// algorithm are rejected.
+	Algorithm string
+	// Secret signs and verifies HS256 tokens. It should be at least 32 bytes.
+	Secret []byte
+	// PrivateKeyPEM signs RS256 tokens. PublicKeyPEM verifies them and may be
+	// left empty when the private key is set; services that only verify
+	// tokens need only the public key.
+	PrivateKeyPEM []byte
+	PublicKeyPEM  []byte
+	// KeyID is written to the kid header so verifiers can pick the key.
+	KeyID string
+	// Issuer and Audience are set on generated tokens and required on
+	// validated tokens when not empty.
+	Issuer   string
+	Audience string
+	// TTL is the lifetime of generated tokens without an expiry. Defaults to
+	// 15 minutes.
+	TTL time.Duration
+	// Leeway tolerates clock skew between servers when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// JWTClaims are the registered JWT claims plus any private claims.
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt int64
+	NotBefore int64
+	IssuedAt  int64
+	ID        string
+	// Custom holds private claims. Names of registered claims are ignored.
+	Custom map[string]any
+}
+
+var registeredClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+func (c JWTClaims) MarshalJSON() ([]byte, error) {
+	payload := map[string]any{}
+	for name, value := range c.Custom {
+		if !slices.Contains(registeredClaims, name) {
+			payload[name] = value
+		}
+	}
+	set := func(name string, value any, ok bool) {
+		if ok {
+			payload[name] = value
+		}
+	}
+	set("iss", c.Issuer, c.Issuer != "")
+	set("sub", c.Subject, c.Subject != "")
+	if len(c.Audience) == 1 {
+		payload["aud"] = c.Audience[0]
+	} else {
+		set("aud", c.Audience, len(c.Audience) > 1)
+	}
+	set("exp", c.ExpiresAt, c.ExpiresAt != 0)
+	set("nbf", c.NotBefore, c.NotBefore != 0)
+	set("iat", c.IssuedAt, c.IssuedAt != 0)
+	set("jti", c.ID, c.ID != "")
+	return json.Marshal(payload)
+}
+
+func (c *JWTClaims) UnmarshalJSON(data []byte) error {
+	var registered struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt json.Number     `json:"exp"`
+		NotBefore json.Number     `json:"nbf"`
+		IssuedAt  json.Number     `json:"iat"`
+		ID        string          `json:"jti"`
+	}
+	if err := json.Unmarshal(data, &registered); err != nil {
+		return err
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	for _, name := range registeredClaims {
+		delete(payload, name)
+	}
+
+	*c = JWTClaims{Issuer: registered.Issuer, Subject: registered.Subject, ID: registered.ID, Custom: payload}
+
+	if len(registered.Audience) > 0 {
+		var single string
+		if err := json.Unmarshal(registered.Audience, &single); err == nil {
+			c.Audience = []string{single}
+		} else if err := json.Unmarshal(registered.Audience, &c.Audience); err != nil {
+			return fmt.Errorf("invalid aud claim: %w", err)
+		}
+	}
+
+	for _, field := range []struct {
+		value json.Number
+		dest  *int64
+	}{
+		{registered.ExpiresAt, &c.ExpiresAt},
+		{registered.NotBefore, &c.NotBefore},
+		{registered.IssuedAt, &c.IssuedAt},
+	} {
+		if field.value == "" {
+			continue
+		}
+		seconds, err := field.value.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid numeric date %s: %w", field.value, err)
+		}
+		*field.dest = int64(seconds)
+	}
+	return nil
+}
+
+// GenerateJWT signs claims into a compact JWT. Missing iat, exp, jti, iss
+// and aud claims are filled in from the current time and cfg.
+func GenerateJWT(cfg JWTConfig, claims JWTClaims) (string, error) {
+	now := time.Now()
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if claims.ExpiresAt == 0 {
+		ttl := cfg.TTL
+		if ttl == 0 {
+			ttl = 15 * time.Minute
+		}
+		claims.ExpiresAt = now.Add(ttl).Unix()
+	}
+	if claims.ID == "" {
+		claims.ID = uuid.NewString()
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = cfg.Issuer
+	}
+	if len(claims.Audience) == 0 && cfg.Audience != "" {
+		claims.Audience = []string{cfg.Audience}
+	}
+
+	header := map[string]string{"alg": cfg.Algorithm, "typ": "JWT"}
+	if cfg.KeyID != "" {
+		header["kid"] = cfg.KeyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch cfg.Algorithm {
+	case JWTAlgHS256:
+		if len(cfg.Secret) == 0 {
+			return "", fmt.Errorf("JWT secret cannot be empty")
+		}
+		signature = hmacSHA256(cfg.Secret, []byte(signingInput))
+	case JWTAlgRS256:
+		key, err := parseRSAPrivateKey(cfg.PrivateKeyPEM)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			return "", fmt.Errorf("failed to sign JWT: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unsupported JWT algorithm: %s", cfg.Algorithm)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ValidateJWT verifies the signature of token with the configured algorithm
+// and key, checks its time, issuer and audience claims and returns them.
+func ValidateJWT(cfg JWTConfig, token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed JWT")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	// The algorithm is fixed by configuration, never chosen by the token.
+	if header.Algorithm != cfg.Algorithm {
+		return nil, fmt.Errorf("unexpected JWT algorithm: %s", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT signature: %w", err)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	switch cfg.Algorithm {
+	case JWTAlgHS256:
+		if len(cfg.Secret) == 0 {
+			return nil, fmt.Errorf("JWT secret cannot be empty")
+		}
+		if !hmac.Equal(signature, hmacSHA256(cfg.Secret, signingInput)) {
+			return nil, fmt.Errorf("invalid JWT signature")
+		}
+	case JWTAlgRS256:
+		key, err := rsaPublicKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(signingInput)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, fmt.Errorf("invalid JWT signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", cfg.Algorithm)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	claims := &JWTClaims{}
+	if err := json.Unmarshal(claimsJSON, claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(cfg.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(cfg.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, fmt.Errorf("token not valid yet")
+	}
+	if cfg.Issuer != "" && claims.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("unexpected token issuer: %s", claims.Issuer)
+	}
+	if cfg.Audience != "" && !slices.Contains(claims.Audience, cfg.Audience) {
+		return nil, fmt.Errorf("token not issued for audience %s", cfg.Audience)
+	}
+
+	return claims, nil
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func parseRSAPrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("RSA private key is not PEM encoded")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func rsaPublicKey(cfg JWTConfig) (*rsa.PublicKey, error) {
+	if len(cfg.PublicKeyPEM) == 0 {
+		key, err := parseRSAPrivateKey(cfg.PrivateKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	}
+
+	block, _ := pem.Decode(cfg.PublicKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("RSA public key is not PEM encoded")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
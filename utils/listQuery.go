@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ListQuery holds the paging, sorting and filtering parameters of a list
+// request, ready to be passed to storage.FindSortedData:
+//
+//	q, err := utils.ParseListQuery(r.URL.Query())
+//	storage.FindSortedData(ctx, "orders", q.Filter, q.Page, q.PageSize, q.Sort)
+type ListQuery struct {
+	Page     int
+	PageSize int
+	Sort     bson.D
+	Filter   bson.M
+}
+
+type ListQueryOptions struct {
+	// DefaultPageSize defaults to 20.
+	DefaultPageSize int
+	// MaxPageSize caps the requested page size. Defaults to 100.
+	MaxPageSize int
+	// DefaultSort is used when the request has no sort, e.g. "-createdAt".
+	DefaultSort string
+	// SortFields restricts which fields may be sorted on. Empty means any
+	// field.
+	SortFields []string
+	// FilterFields are the parameters turned into equality filters. Empty
+	// means no filtering.
+	FilterFields []string
+}
+
+// ParseListQuery parses page, pageSize and sort parameters with the default
+// options. Sort is a comma separated list of fields, descending when
+// prefixed with "-", e.g. "-createdAt,name". Filters are only read with
+// ParseListQueryWith and FilterFields.
+func ParseListQuery(values url.Values) (*ListQuery, error) {
+	return ParseListQueryWith(values, ListQueryOptions{})
+}
+
+// ParseListQueryWith is ParseListQuery with custom defaults and allowed fields.
+func ParseListQueryWith(values url.Values, opts ListQueryOptions) (*ListQuery, error) {
+	if opts.DefaultPageSize <= 0 {
+		opts.DefaultPageSize = 20
+	}
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = 100
+	}
+
+	query := &ListQuery{Page: 1, PageSize: opts.DefaultPageSize, Filter: bson.M{}}
+
+	if page := values.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid page %q", page)
+		}
+		query.Page = n
+	}
+	if pageSize := values.Get("pageSize"); pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid pageSize %q", pageSize)
+		}
+		query.PageSize = min(n, opts.MaxPageSize)
+	}
+
+	sort := values.Get("sort")
+	if sort == "" {
+		sort = opts.DefaultSort
+	}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		order := 1
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			field, order = name, -1
+		}
+		if !validQueryField(field, opts.SortFields) {
+			return nil, fmt.Errorf("cannot sort by %q", field)
+		}
+		query.Sort = append(query.Sort, bson.E{Key: field, Value: order})
+	}
+
+	// Other parameters, e.g. cache busters, are ignored.
+	for _, field := range opts.FilterFields {
+		if vals := values[field]; len(vals) > 0 && validQueryField(field, nil) {
+			query.Filter[field] = vals[0]
+		}
+	}
+
+	return query, nil
+}
+
+// validQueryField rejects operator-like names so request parameters can
+// never inject query operators.
+func validQueryField(field string, allowed []string) bool {
+	if field == "" || strings.HasPrefix(field, "$") {
+		return false
+	}
+	return len(allowed) == 0 || slices.Contains(allowed, field)
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// MaskEmail hides the local part of an email address except its first
+// character, e.g. "john.doe@example.com" becomes "j*******@example.com".
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return strings.Repeat("*", len([]rune(email)))
+	}
+	runes := []rune(local)
+	if len(runes) <= 1 {
+		return strings.Repeat("*", len(runes)) + "@" + domain
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1) + "@" + domain
+}
+
+// MaskPhone hides all but the last four digits of a phone number, keeping
+// its formatting, e.g. "+263 77 123 4567" becomes "+*** ** *** 4567".
+func MaskPhone(phone string) string {
+	return maskDigits(phone, 4)
+}
+
+// MaskCard hides all but the last four digits of a card number, keeping its
+// formatting, e.g. "4111 1111 1111 1111" becomes "**** **** **** 1111".
+func MaskCard(number string) string {
+	return maskDigits(number, 4)
+}
+
+func maskDigits(value string, keep int) string {
+	digits := 0
+	for _, r := range value {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, r := range value {
+		if unicode.IsDigit(r) {
+			seen++
+			if seen <= digits-keep {
+				r = '*'
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Redact returns a copy of v in which every struct field tagged
+// `redact:"true"` is set to its zero value, including fields of nested
+// structs, pointers, slices and maps. v itself is not modified. Values with
+// pointer cycles are not supported.
+func Redact(v any) any {
+	if v == nil {
+		return nil
+	}
+	return redactValue(reflect.ValueOf(v)).Interface()
+}
+
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(redactValue(v.Elem()))
+		return out
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(redactValue(v.Elem()))
+		return out
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get("redact") == "true" {
+				out.Field(i).SetZero()
+				continue
+			}
+			out.Field(i).Set(redactValue(v.Field(i)))
+		}
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return out
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordParams are the argon2id cost parameters used by HashPassword.
+type PasswordParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultPasswordParams follow the RFC 9106 recommendation for memory
+// constrained environments. Raising them makes NeedsRehash report existing
+// hashes so they can be upgraded at the next login.
+var DefaultPasswordParams = PasswordParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// HashPassword hashes password with argon2id and DefaultPasswordParams. The
+// result is in the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>, and embeds everything needed
+// to verify it.
+func HashPassword(password string) (string, error) {
+	params := DefaultPasswordParams
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches hash. Both argon2id hashes
+// from HashPassword and bcrypt hashes are accepted, so existing bcrypt
+// credentials keep working.
+func VerifyPassword(password string, hash string) (bool, error) {
+	if isBcryptHash(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return true, nil
+	}
+
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+// NeedsRehash reports whether hash was created with bcrypt or with weaker
+// parameters than DefaultPasswordParams. Call it after a successful
+// VerifyPassword and store a fresh HashPassword result when it returns true.
+func NeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		return true
+	}
+
+	params, _, _, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+	defaults := DefaultPasswordParams
+	return params.Memory < defaults.Memory ||
+		params.Iterations < defaults.Iterations ||
+		params.Parallelism < defaults.Parallelism ||
+		params.SaltLength < defaults.SaltLength ||
+		params.KeyLength < defaults.KeyLength
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+const (
+	// maxArgon2Memory is the largest memory cost, in KiB, accepted from a
+	// stored hash.
+	maxArgon2Memory    = 1024 * 1024
+	minArgon2KeyLength = 16
+)
+
+func decodeArgon2Hash(hash string) (PasswordParams, []byte, []byte, error) {
+	var params PasswordParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, fmt.Errorf("unsupported password hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	// argon2.IDKey panics on some of these, and a stored hash with a huge
+	// memory cost would exhaust the server on every login attempt.
+	switch {
+	case params.Iterations < 1:
+		return params, nil, nil, fmt.Errorf("invalid argon2id iterations %d", params.Iterations)
+	case params.Parallelism < 1:
+		return params, nil, nil, fmt.Errorf("invalid argon2id parallelism %d", params.Parallelism)
+	case params.Memory < 8*uint32(params.Parallelism) || params.Memory > maxArgon2Memory:
+		return params, nil, nil, fmt.Errorf("invalid argon2id memory %d KiB", params.Memory)
+	case len(salt) == 0:
+		return params, nil, nil, fmt.Errorf("argon2id salt is empty")
+	case len(key) < minArgon2KeyLength:
+		return params, nil, nil, fmt.Errorf("argon2id hash is shorter than %d bytes", minArgon2KeyLength)
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlphabetAlphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	AlphabetDigits       = "0123456789"
+	// AlphabetUnambiguous leaves out characters that are easily confused when
+	// read or typed, such as 0/O and 1/l/I.
+	AlphabetUnambiguous = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+)
+
+// RandomString returns n characters picked uniformly from alphabet using
+// crypto/rand. An empty alphabet means AlphabetAlphanumeric.
+func RandomString(n int, alphabet string) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("invalid length %d", n)
+	}
+	if alphabet == "" {
+		alphabet = AlphabetAlphanumeric
+	}
+
+	chars := []rune(alphabet)
+	max := big.NewInt(int64(len(chars)))
+	out := make([]rune, n)
+	for i := range out {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out[i] = chars[index.Int64()]
+	}
+	return string(out), nil
+}
+
+// RandomDigits returns a numeric code of n digits, e.g. for verification codes.
+// Leading zeros are kept, so the result should be handled as a string.
+func RandomDigits(n int) (string, error) {
+	return RandomString(n, AlphabetDigits)
+}
+
+// RandomHexKey returns size random bytes, hex encoded. RandomHexKey(32)
+// generates a key for EncryptData and the token functions.
+func RandomHexKey(size int) (string, error) {
+	if size <= 0 {
+		return "", fmt.Errorf("invalid key size %d", size)
+	}
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// NewUUIDv7 returns a time ordered UUID, which makes a better database key
+// than a random v4 UUID because new IDs sort after older ones.
+func NewUUIDv7() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
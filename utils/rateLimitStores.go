@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/delightmichael1/go-libs/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRateLimitStore struct {
+	collection string
+}
+
+// NewMongoRateLimitStore returns a store that keeps state in a MongoDB
+// collection shared by all processes, with a TTL index removing idle keys.
+// The storage package must be initialized. Requires MongoDB 4.2 or later.
+func NewMongoRateLimitStore(ctx context.Context, collection string) (RateLimitStore, error) {
+	if err := storage.EnsureTTLIndex(ctx, collection, "expireAt", 0); err != nil {
+		return nil, fmt.Errorf("failed to create rate limit TTL index: %w", err)
+	}
+	return &mongoRateLimitStore{collection: collection}, nil
+}
+
+func (s *mongoRateLimitStore) TakeToken(ctx context.Context, key string, capacity float64, perSecond float64, now time.Time) (bool, float64, error) {
+	// Refill and take happen in one pipeline update, so the operation is
+	// atomic per key.
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{
+					bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$last", now}}}}, 1000}},
+					perSecond,
+				}},
+			}}}},
+			"last": now,
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed":  bson.M{"$gte": bson.A{"$tokens", 1}},
+			"tokens":   bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$tokens", 1}}, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expireAt": now.Add(time.Duration(capacity / perSecond * float64(time.Second))),
+		}}},
+	}
+
+	var state struct {
+		Allowed bool    `bson:"allowed"`
+		Tokens  float64 `bson:"tokens"`
+	}
+	if err := s.update(ctx, key, pipeline, &state); err != nil {
+		return false, 0, err
+	}
+	return state.Allowed, state.Tokens, nil
+}
+
+func (s *mongoRateLimitStore) CountRequest(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, int64, int64, error) {
+	start := windowStart(now, window)
+	weight := 1 - float64(now.Sub(start))/float64(window)
+	sameWindow := bson.M{"$eq": bson.A{"$windowStart", start}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"prev": bson.M{"$cond": bson.A{
+				sameWindow,
+				bson.M{"$ifNull": bson.A{"$prev", 0}},
+				bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{"$windowStart", start.Add(-window)}},
+					bson.M{"$ifNull": bson.A{"$curr", 0}},
+					0,
+				}},
+			}},
+			"curr":        bson.M{"$cond": bson.A{sameWindow, bson.M{"$ifNull": bson.A{"$curr", 0}}, 0}},
+			"windowStart": start,
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed": bson.M{"$lte": bson.A{
+				bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{"$prev", weight}}, "$curr", 1}},
+				limit,
+			}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"curr":     bson.M{"$cond": bson.A{"$allowed", bson.M{"$add": bson.A{"$curr", 1}}, "$curr"}},
+			"expireAt": start.Add(2 * window),
+		}}},
+	}
+
+	var state struct {
+		Allowed bool  `bson:"allowed"`
+		Prev    int64 `bson:"prev"`
+		Curr    int64 `bson:"curr"`
+	}
+	if err := s.update(ctx, key, pipeline, &state); err != nil {
+		return false, 0, 0, err
+	}
+	return state.Allowed, state.Prev, state.Curr, nil
+}
+
+func (s *mongoRateLimitStore) update(ctx context.Context, key string, pipeline mongo.Pipeline, out any) error {
+	collection := storage.GetCollectionRef(ctx, s.collection)
+	if collection == nil {
+		return fmt.Errorf("failed to get collection %s", s.collection)
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(out)
+}
+
+// RedisEvaler runs a Lua script, as redis EVAL does. It keeps this package
+// independent of a Redis client; with go-redis, use
+//
+//	utils.RedisEvalFunc(func(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return rdb.Eval(ctx, script, keys, args...).Result()
+//	})
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// RedisEvalFunc adapts a function to RedisEvaler.
+type RedisEvalFunc func(ctx context.Context, script string, keys []string, args ...any) (any, error)
+
+func (f RedisEvalFunc) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	return f(ctx, script, keys, args...)
+}
+
+const redisTokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or capacity
+local last = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000))
+return {allowed, tostring(tokens)}
+`
+
+const redisSlidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local start = now - (now % window)
+local state = redis.call('HMGET', KEYS[1], 'start', 'prev', 'curr')
+local prev = tonumber(state[2]) or 0
+local curr = tonumber(state[3]) or 0
+if tonumber(state[1]) ~= start then
+  if tonumber(state[1]) == start - window then
+    prev = curr
+  else
+    prev = 0
+  end
+  curr = 0
+end
+local allowed = 0
+if prev * (1 - (now - start) / window) + curr + 1 <= limit then
+  curr = curr + 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'start', start, 'prev', prev, 'curr', curr)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {allowed, prev, curr}
+`
+
+type redisRateLimitStore struct {
+	client RedisEvaler
+}
+
+// NewRedisRateLimitStore returns a store that keeps state in Redis using
+// Lua scripts, so each operation is atomic. Requires Redis 4 or later.
+func NewRedisRateLimitStore(client RedisEvaler) RateLimitStore {
+	return &redisRateLimitStore{client: client}
+}
+
+func (s *redisRateLimitStore) TakeToken(ctx context.Context, key string, capacity float64, perSecond float64, now time.Time) (bool, float64, error) {
+	reply, err := s.eval(ctx, redisTokenBucketScript, key, 2, capacity, perSecond, now.UnixMilli())
+	if err != nil {
+		return false, 0, err
+	}
+	tokens, err := strconv.ParseFloat(fmt.Sprint(reply[1]), 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	return redisInt(reply[0]) == 1, tokens, nil
+}
+
+func (s *redisRateLimitStore) CountRequest(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, int64, int64, error) {
+	reply, err := s.eval(ctx, redisSlidingWindowScript, key, 3, limit, window.Milliseconds(), now.UnixMilli())
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return redisInt(reply[0]) == 1, redisInt(reply[1]), redisInt(reply[2]), nil
+}
+
+func (s *redisRateLimitStore) eval(ctx context.Context, script string, key string, replyLen int, args ...any) ([]any, error) {
+	result, err := s.client.Eval(ctx, script, []string{key}, args...)
+	if err != nil {
+		return nil, err
+	}
+	reply, ok := result.([]any)
+	if !ok || len(reply) != replyLen {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", result)
+	}
+	return reply, nil
+}
+
+func redisInt(value any) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	default:
+		n, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		return n
+	}
+}
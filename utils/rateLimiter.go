@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/delightmichael1/go-libs/logging"
+)
+
+const (
+	// RateLimitTokenBucket allows bursts of up to Limit requests and refills
+	// at Limit requests per Window.
+	RateLimitTokenBucket = "token_bucket"
+	// RateLimitSlidingWindow allows Limit requests in any Window, estimated
+	// from the counts of the current and previous fixed windows.
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimitStore keeps the per-key state of rate limiters. Both operations
+// must be atomic per key, so that concurrent requests, possibly from
+// several processes, cannot exceed the limit.
+type RateLimitStore interface {
+	// TakeToken refills the bucket of key at perSecond tokens per second up
+	// to capacity, then takes one token if available. It returns whether a
+	// token was taken and how many remain.
+	TakeToken(ctx context.Context, key string, capacity float64, perSecond float64, now time.Time) (taken bool, tokens float64, err error)
+	// CountRequest counts a request for key in the window starting at
+	// now.Truncate(window) when prev*weight+curr+1 <= limit, where weight is
+	// the share of the previous window still covered by the sliding window.
+	// It returns whether the request was counted and the counts of the
+	// previous and current windows.
+	CountRequest(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (allowed bool, prev, curr int64, err error)
+}
+
+type RateLimiterConfig struct {
+	// Strategy is RateLimitTokenBucket (default) or RateLimitSlidingWindow.
+	Strategy string
+	Limit    int
+	Window   time.Duration
+	// Prefix namespaces the keys of this limiter, so that several limiters
+	// can share a store.
+	Prefix string
+	// Store defaults to an in-memory store, which is per process.
+	Store RateLimitStore
+}
+
+// RateLimitResult is the outcome of RateLimiter.Allow.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long to wait before the next request may be allowed.
+	// It is only set when the request was rejected.
+	RetryAfter time.Duration
+}
+
+// RateLimiter limits the rate of requests per key, e.g. per user ID or IP.
+type RateLimiter struct {
+	config RateLimiterConfig
+}
+
+func NewRateLimiter(cfg RateLimiterConfig) (*RateLimiter, error) {
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive")
+	}
+	if cfg.Window <= 0 {
+		return nil, fmt.Errorf("rate limit window must be positive")
+	}
+	if cfg.Strategy == "" {
+		cfg.Strategy = RateLimitTokenBucket
+	}
+	if cfg.Strategy != RateLimitTokenBucket && cfg.Strategy != RateLimitSlidingWindow {
+		return nil, fmt.Errorf("unknown rate limit strategy: %s", cfg.Strategy)
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{config: cfg}, nil
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	cfg := l.config
+	now := time.Now()
+	storeKey := cfg.Prefix + cfg.Strategy + ":" + key
+
+	if cfg.Strategy == RateLimitTokenBucket {
+		perSecond := float64(cfg.Limit) / cfg.Window.Seconds()
+		taken, tokens, err := cfg.Store.TakeToken(ctx, storeKey, float64(cfg.Limit), perSecond, now)
+		if err != nil {
+			return RateLimitResult{}, fmt.Errorf("failed to take rate limit token: %w", err)
+		}
+		result := RateLimitResult{Allowed: taken, Remaining: int(math.Floor(tokens))}
+		if !taken {
+			result.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+		}
+		return result, nil
+	}
+
+	allowed, prev, curr, err := cfg.Store.CountRequest(ctx, storeKey, cfg.Limit, cfg.Window, now)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to count rate limited request: %w", err)
+	}
+	elapsed := now.Sub(windowStart(now, cfg.Window))
+	weight := 1 - float64(elapsed)/float64(cfg.Window)
+	estimate := float64(prev)*weight + float64(curr)
+
+	result := RateLimitResult{Allowed: allowed, Remaining: max(0, cfg.Limit-int(math.Ceil(estimate)))}
+	if !allowed {
+		result.RetryAfter = slidingRetryAfter(cfg.Limit, cfg.Window, elapsed, prev, curr)
+	}
+	return result, nil
+}
+
+// slidingRetryAfter estimates when prev*weight+curr+1 drops to limit.
+func slidingRetryAfter(limit int, window, elapsed time.Duration, prev, curr int64) time.Duration {
+	w := float64(window)
+	if curr+1 <= int64(limit) && prev > 0 {
+		return time.Duration(w*(1-float64(int64(limit)-curr-1)/float64(prev))) - elapsed
+	}
+	// The current window is full; once it becomes the previous window its
+	// weight must drop far enough.
+	wait := window - elapsed
+	if curr > 0 {
+		wait += time.Duration(w * max(0, 1-float64(limit-1)/float64(curr)))
+	}
+	return wait
+}
+
+// Middleware returns HTTP middleware that rejects requests over the limit
+// with 429 Too Many Requests. key returns the rate limit key of a request,
+// e.g. RemoteIP. Store errors let the request through, so that an outage
+// of the store does not take the endpoint down.
+func (l *RateLimiter) Middleware(key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := l.Allow(r.Context(), key(r))
+			if err != nil {
+				logging.Error("Rate limiter failed, allowing request", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.config.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RemoteIP returns the IP address of the client connection, for use as a
+// rate limit key. Behind a proxy, use a key function that reads the header
+// the proxy sets instead.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func windowStart(now time.Time, window time.Duration) time.Time {
+	ms, windowMs := now.UnixMilli(), window.Milliseconds()
+	return time.UnixMilli(ms - ms%windowMs)
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	windows map[string]*memoryWindow
+	calls   int
+}
+
+type memoryBucket struct {
+	tokens  float64
+	last    time.Time
+	expires time.Time
+}
+
+type memoryWindow struct {
+	start      time.Time
+	prev, curr int64
+	expires    time.Time
+}
+
+// NewMemoryRateLimitStore returns a store that keeps state in memory.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: map[string]*memoryBucket{},
+		windows: map[string]*memoryWindow{},
+	}
+}
+
+func (s *memoryRateLimitStore) TakeToken(_ context.Context, key string, capacity float64, perSecond float64, now time.Time) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, last: now}
+		s.buckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = min(capacity, bucket.tokens+elapsed.Seconds()*perSecond)
+		bucket.last = now
+	}
+	bucket.expires = now.Add(time.Duration(capacity / perSecond * float64(time.Second)))
+
+	if bucket.tokens < 1 {
+		return false, bucket.tokens, nil
+	}
+	bucket.tokens--
+	return true, bucket.tokens, nil
+}
+
+func (s *memoryRateLimitStore) CountRequest(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(now)
+
+	start := windowStart(now, window)
+	state, ok := s.windows[key]
+	if !ok {
+		state = &memoryWindow{start: start}
+		s.windows[key] = state
+	}
+	if !state.start.Equal(start) {
+		if state.start.Equal(start.Add(-window)) {
+			state.prev = state.curr
+		} else {
+			state.prev = 0
+		}
+		state.curr = 0
+		state.start = start
+	}
+	state.expires = start.Add(2 * window)
+
+	weight := 1 - float64(now.Sub(start))/float64(window)
+	if float64(state.prev)*weight+float64(state.curr)+1 > float64(limit) {
+		return false, state.prev, state.curr, nil
+	}
+	state.curr++
+	return true, state.prev, state.curr, nil
+}
+
+// sweepLocked drops expired keys every 1000 calls to bound memory use.
+func (s *memoryRateLimitStore) sweepLocked(now time.Time) {
+	s.calls++
+	if s.calls%1000 != 0 {
+		return
+	}
+	for key, bucket := range s.buckets {
+		if now.After(bucket.expires) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, state := range s.windows {
+		if now.After(state.expires) {
+			delete(s.windows, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+type RetryOptions struct {
+	// MaxAttempts includes the first call. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff defaults to 200ms and doubles after every attempt, up
+	// to MaxBackoff (default 10s).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter randomizes each backoff by up to this fraction, e.g. 0.2 for
+	// ±20%, so that many clients do not retry in lockstep.
+	Jitter float64
+	// Retryable decides whether an error is worth retrying. Defaults to
+	// retrying every error.
+	Retryable func(err error) bool
+}
+
+// Retry calls fn until it succeeds, returns an error that is not retryable,
+// the attempts run out or ctx is done, and returns the last error.
+func Retry(ctx context.Context, opts RetryOptions, fn func() error) error {
+	_, err := RetryValue(ctx, opts, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// RetryValue is Retry for functions that return a value.
+func RetryValue[T any](ctx context.Context, opts RetryOptions, fn func() (T, error)) (T, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 200 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Second
+	}
+
+	backoff := opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		value, err := fn()
+		if err == nil {
+			return value, nil
+		}
+		if attempt >= opts.MaxAttempts || (opts.Retryable != nil && !opts.Retryable(err)) {
+			return value, err
+		}
+
+		timer := time.NewTimer(withJitter(backoff, opts.Jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return value, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	jitter = min(jitter, 1)
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
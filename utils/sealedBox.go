@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// GenerateKeyPair returns a hex encoded X25519 key pair for SealFor and
+// OpenWith. The public key can be shared freely; the private key must be
+// kept secret like the symmetric hex key.
+func GenerateKeyPair() (publicKey string, privateKey string, err error) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(public[:]), hex.EncodeToString(private[:]), nil
+}
+
+// SealFor encrypts plaintext so that only the holder of the private key
+// matching publicKey can decrypt it. The sender stays anonymous: a fresh
+// ephemeral key pair is used for every message.
+func SealFor(publicKey string, plaintext []byte) (string, error) {
+	recipient, err := decodeBoxKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := box.SealAnonymous(nil, plaintext, recipient, rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sealed), nil
+}
+
+// OpenWith decrypts a ciphertext from SealFor with the recipient's private key.
+func OpenWith(privateKey string, ciphertext string) ([]byte, error) {
+	private, err := decodeBoxKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := hex.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid hex encoding", ErrMalformedCiphertext)
+	}
+	if len(sealed) < box.AnonymousOverhead {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrMalformedCiphertext)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(private[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	var public [32]byte
+	copy(public[:], key.PublicKey().Bytes())
+
+	plaintext, ok := box.OpenAnonymous(nil, sealed, &public, private)
+	if !ok {
+		return nil, fmt.Errorf("%w: authentication failed", ErrMalformedCiphertext)
+	}
+	return plaintext, nil
+}
+
+func decodeBoxKey(hexKey string) (*[32]byte, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key is not hex encoded", ErrInvalidKey)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w: key must be 32 bytes, got %d", ErrInvalidKey, len(key))
+	}
+	var out [32]byte
+	copy(out[:], key)
+	return &out, nil
+}
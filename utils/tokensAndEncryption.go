@@ -4,91 +4,111 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"time"
 
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
+var (
+	// ErrInvalidKey is returned when a key is not a hex encoded 32 byte key.
+	ErrInvalidKey = errors.New("invalid encryption key")
+	// ErrMalformedCiphertext is returned when a ciphertext or token cannot be
+	// decoded or fails authentication, e.g. because it was tampered with or
+	// encrypted under another key.
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+	ErrTokenExpired        = errors.New("token expired")
+)
+
 type Claims struct {
-	Id        string `json:"id"`
-	ExpiresAt int64  `json:"expiresAt"`
-	IssuedAt  int64  `json:"issuedAt"`
+	Id        string   `json:"id"`
+	ExpiresAt int64    `json:"expiresAt"`
+	IssuedAt  int64    `json:"issuedAt"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
 }
 
 func GenerateAccessToken(userId string, hexKey string) (string, error) {
-	claims := Claims{
-		Id:        userId,
-		ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
-		IssuedAt:  time.Now().Unix(),
-	}
-
-	claimsJSON, err := json.Marshal(claims)
-	if err != nil {
-		panic(err)
-	}
+	return generateToken(Claims{Id: userId}, hexKey, 15*time.Minute, nil)
+}
 
-	claimsBytes := []byte(string(claimsJSON))
+func GenerateRefreshToken(userId string, hexKey string) (string, error) {
+	return generateToken(Claims{Id: userId}, hexKey, 7*24*time.Hour, nil)
+}
 
-	accessToken, err := EncryptData(claimsBytes, hexKey)
+// GenerateAccessTokenFor issues an access token carrying the ID, roles and
+// scopes of claims. The expiry and issue times are set here.
+func GenerateAccessTokenFor(claims Claims, hexKey string) (string, error) {
+	return generateToken(claims, hexKey, 15*time.Minute, nil)
+}
 
-	if err != nil {
-		return "", err
-	}
+// GenerateRefreshTokenFor is GenerateAccessTokenFor for refresh tokens.
+func GenerateRefreshTokenFor(claims Claims, hexKey string) (string, error) {
+	return generateToken(claims, hexKey, 7*24*time.Hour, nil)
+}
 
-	return accessToken, nil
+// GenerateAccessTokenWith is GenerateAccessToken with encryption options,
+// e.g. EncodingBase64URL for shorter tokens. ValidateToken accepts tokens in
+// every format.
+func GenerateAccessTokenWith(userId string, hexKey string, opts EncryptOptions) (string, error) {
+	return generateToken(Claims{Id: userId}, hexKey, 15*time.Minute, &opts)
 }
 
-func GenerateRefreshToken(userId string, hexKey string) (string, error) {
-	claims := Claims{
-		Id:        userId,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour).Unix(),
-		IssuedAt:  time.Now().Unix(),
-	}
+// GenerateRefreshTokenWith is GenerateRefreshToken with encryption options.
+func GenerateRefreshTokenWith(userId string, hexKey string, opts EncryptOptions) (string, error) {
+	return generateToken(Claims{Id: userId}, hexKey, 7*24*time.Hour, &opts)
+}
 
+// generateToken encrypts claims valid for ttl, in the legacy format of
+// EncryptData when opts is nil.
+func generateToken(claims Claims, hexKey string, ttl time.Duration, opts *EncryptOptions) (string, error) {
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = time.Now().Add(ttl).Unix()
 	claimsJSON, err := json.Marshal(claims)
-	if err != nil {
-		panic(err)
-	}
-
-	claimsBytes := []byte(string(claimsJSON))
-
-	refreshToken, err := EncryptData(claimsBytes, hexKey)
-
 	if err != nil {
 		return "", err
 	}
 
-	return refreshToken, nil
+	if opts == nil {
+		return EncryptData(claimsJSON, hexKey)
+	}
+	return EncryptDataWith(claimsJSON, hexKey, *opts)
 }
 
+// ValidateToken decrypts a token and checks its expiry. It returns
+// ErrTokenExpired for expired tokens and ErrMalformedCiphertext for tokens
+// that were not issued with hexKey or were tampered with.
 func ValidateToken(tokenStr string, hexKey string) (*Claims, error) {
-	plaintext, err := DecryptData(tokenStr, hexKey)
+	return ParseClaims(tokenStr, hexKey, false)
+}
 
+// ParseClaims decrypts a token and returns its claims. With allowExpired the
+// expiry is not enforced, e.g. so a refresh endpoint can identify the user of
+// an expired access token; integrity is always verified.
+func ParseClaims(tokenStr string, hexKey string, allowExpired bool) (*Claims, error) {
+	plaintext, err := DecryptData(tokenStr, hexKey)
 	if err != nil {
 		return nil, err
 	}
 
 	claims := &Claims{}
-	err = json.Unmarshal([]byte(plaintext), claims)
-
-	if err != nil {
-		return nil, err
+	if err := json.Unmarshal([]byte(plaintext), claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid token claims", ErrMalformedCiphertext)
 	}
 
-	if claims.ExpiresAt < time.Now().Unix() {
-		return nil, fmt.Errorf("token expired")
+	if !allowExpired && claims.ExpiresAt < time.Now().Unix() {
+		return nil, ErrTokenExpired
 	}
 
 	return claims, nil
 }
 
 func EncryptData(plaintext []byte, hexKey string) (string, error) {
-	key, err := hex.DecodeString(hexKey)
+	key, err := decodeKey(hexKey)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	aead, err := chacha20poly1305.New(key)
@@ -106,16 +126,19 @@ func EncryptData(plaintext []byte, hexKey string) (string, error) {
 	return hex.EncodeToString(append(nonce, ciphertext...)), nil
 }
 
+// DecryptData decrypts ciphertexts from EncryptData and EncryptDataWith.
 func DecryptData(ciphertextHex string, hexKey string) (string, error) {
-
-	key, err := hex.DecodeString(hexKey)
+	key, err := decodeKey(hexKey)
 	if err != nil {
-		log.Println("Error decoding key:", err)
-		panic(err)
+		return "", err
 	}
+	if isEnvelope(ciphertextHex) {
+		return decryptEnvelope(ciphertextHex, key)
+	}
+
 	ciphertext, err := hex.DecodeString(ciphertextHex)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: invalid hex encoding", ErrMalformedCiphertext)
 	}
 
 	aead, err := chacha20poly1305.New(key)
@@ -124,12 +147,26 @@ func DecryptData(ciphertextHex string, hexKey string) (string, error) {
 	}
 
 	nonceSize := aead.NonceSize()
+	if len(ciphertext) < nonceSize+aead.Overhead() {
+		return "", fmt.Errorf("%w: ciphertext too short", ErrMalformedCiphertext)
+	}
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
 
 	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: authentication failed", ErrMalformedCiphertext)
 	}
 
 	return string(plaintext), nil
 }
+
+func decodeKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key is not hex encoded", ErrInvalidKey)
+	}
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("%w: key must be %d bytes, got %d", ErrInvalidKey, chacha20poly1305.KeySize, len(key))
+	}
+	return key, nil
+}
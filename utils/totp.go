@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is the time step of the codes, in seconds.
+	TOTPPeriod = 30
+	// TOTPDigits is the number of digits in a code.
+	TOTPDigits = 6
+	// totpSecretSize is the length of generated secrets, as recommended by RFC 4226.
+	totpSecretSize = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 encoded secret for use with
+// authenticator apps.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI for secret, which is usually
+// rendered as a QR code for authenticator apps to scan.
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(TOTPPeriod))
+
+	// Some authenticator apps show "+" literally, so spaces are encoded as %20.
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// GenerateHOTP returns the RFC 4226 code for secret at counter.
+func GenerateHOTP(secret string, counter uint64) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter), nil
+}
+
+// GenerateTOTP returns the RFC 6238 code for secret at t.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	return GenerateHOTP(secret, uint64(t.Unix())/TOTPPeriod)
+}
+
+// ValidateTOTP reports whether code is valid for secret now, accepting codes
+// up to skew time steps before or after the current one to allow for clock
+// drift. A skew of 1 is usually enough.
+func ValidateTOTP(code, secret string, skew uint) (bool, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	if len(code) != TOTPDigits {
+		return false, nil
+	}
+
+	counter := uint64(time.Now().Unix()) / TOTPPeriod
+	valid := false
+	for i := -int64(skew); i <= int64(skew); i++ {
+		if int64(counter)+i < 0 {
+			continue
+		}
+		expected := hotp(key, uint64(int64(counter)+i))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			valid = true
+		}
+	}
+	return valid, nil
+}
+
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// decodeTOTPSecret accepts secrets with or without padding, spaces and in
+// lower case, as users often type them in that way.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("%w: invalid TOTP secret", ErrInvalidKey)
+	}
+	return key, nil
+}
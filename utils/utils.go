@@ -6,6 +6,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Transcode copies in into out through a JSON round trip. Decode is faster
+// and keeps types such as time.Time and primitive.ObjectID intact; Transcode
+// remains for types that rely on custom JSON marshalling.
 func Transcode(in, out any) error {
 	resultBytes, err := json.Marshal(in)
 	if err != nil {
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that failed validation. Field is the path of
+// the field using JSON names, e.g. "address.city" or "items[2].sku".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidatorFunc validates value against the rule's parameter, e.g. "5" for
+// `validate:"divisible=5"`. A non-nil error fails the field with its message.
+type ValidatorFunc func(value any, param string) error
+
+var (
+	validators   = map[string]ValidatorFunc{}
+	validatorsMu sync.RWMutex
+)
+
+// RegisterValidator adds a custom rule that can be used in validate tags.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = fn
+}
+
+// ValidateStruct checks v, a struct or pointer to struct, against the rules
+// in its `validate` tags and returns one FieldError per failed rule, or nil
+// when v is valid. Rules are separated by commas:
+//
+//	Name  string `json:"name" validate:"required,max=50"`
+//	Email string `json:"email" validate:"required,email"`
+//	Role  string `json:"role" validate:"oneof=admin user"`
+//
+// Supported rules are required, min, max, email, url, oneof and any rule
+// added with RegisterValidator. min and max compare the length of strings,
+// slices and maps and the value of numbers. Rules other than required are
+// skipped for zero values, so optional fields may be left empty. Nested
+// structs, including those in slices, are validated too.
+func ValidateStruct(v any) []FieldError {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return []FieldError{{Rule: "required", Message: "value is nil"}}
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return []FieldError{{Rule: "struct", Message: fmt.Sprintf("cannot validate %s, expected a struct", value.Kind())}}
+	}
+
+	var errs []FieldError
+	validateStruct(value, "", &errs)
+	return errs
+}
+
+func validateStruct(value reflect.Value, prefix string, errs *[]FieldError) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		path := prefix + fieldName(field)
+		fieldValue := value.Field(i)
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+				if msg := checkRule(fieldValue, name, param); msg != "" {
+					*errs = append(*errs, FieldError{Field: path, Rule: name, Message: msg})
+				}
+			}
+		}
+
+		validateNested(fieldValue, path, errs)
+	}
+}
+
+func validateNested(value reflect.Value, path string, errs *[]FieldError) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		validateStruct(value, path+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateNested(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+// checkRule returns a message describing why value fails rule, or "" when it
+// passes.
+func checkRule(value reflect.Value, rule string, param string) string {
+	if rule == "required" {
+		if value.IsZero() || (isCollection(value) && value.Len() == 0) {
+			return "is required"
+		}
+		return ""
+	}
+	if value.IsZero() {
+		return ""
+	}
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	switch rule {
+	case "min", "max":
+		return checkBound(value, rule, param)
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if value.Kind() != reflect.String || err != nil || address.Address != value.String() {
+			return "must be a valid email address"
+		}
+	case "url":
+		parsed, err := url.ParseRequestURI(value.String())
+		if value.Kind() != reflect.String || err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return "must be a valid URL"
+		}
+	case "oneof":
+		options := strings.Fields(param)
+		if !slices.Contains(options, fmt.Sprint(value.Interface())) {
+			return "must be one of " + strings.Join(options, ", ")
+		}
+	default:
+		validatorsMu.RLock()
+		fn, ok := validators[rule]
+		validatorsMu.RUnlock()
+		if !ok {
+			return fmt.Sprintf("unknown validation rule %q", rule)
+		}
+		if err := fn(value.Interface(), param); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+func checkBound(value reflect.Value, rule string, param string) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Sprintf("invalid %s parameter %q", rule, param)
+	}
+
+	var actual float64
+	unit := ""
+	switch value.Kind() {
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(value.String()))
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual = float64(value.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	default:
+		return fmt.Sprintf("%s is not supported for %s", rule, value.Kind())
+	}
+
+	if unit == "" {
+		if rule == "min" && actual < limit {
+			return "must be at least " + param
+		}
+		if rule == "max" && actual > limit {
+			return "must be at most " + param
+		}
+		return ""
+	}
+	if rule == "min" && actual < limit {
+		return "must have at least " + param + unit
+	}
+	if rule == "max" && actual > limit {
+		return "must have at most " + param + unit
+	}
+	return ""
+}
+
+func isCollection(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
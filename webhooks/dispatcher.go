@@ -0,0 +1,245 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/delightmichael1/go-libs/jobs"
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// deliverJob is the name of the jobs handler that sends deliveries.
+const deliverJob = "webhooks.deliver"
+
+type Config struct {
+	// EndpointsCollection defaults to "webhook_endpoints" and
+	// DeliveriesCollection to "webhook_deliveries". The storage package must
+	// be initialized, and deliveries are sent by the jobs workers, which the
+	// application starts with jobs.Start. Retry backoff follows jobs.Config.
+	EndpointsCollection  string
+	DeliveriesCollection string
+	// Timeout bounds each HTTP request. Defaults to 10 seconds.
+	Timeout time.Duration
+	// MaxAttempts includes the first attempt. Defaults to 8.
+	MaxAttempts int
+	// HTTPClient defaults to SafeHTTPClient. Replace it only when endpoints
+	// are trusted, e.g. to deliver to localhost in development.
+	HTTPClient *http.Client
+	// UserAgent defaults to "go-libs-webhooks".
+	UserAgent string
+}
+
+// responseLimit is how much of a response body is recorded per attempt.
+const responseLimit = 1024
+
+var (
+	webhooksConfig Config
+	configInit     sync.Once
+	configError    error
+	isInitialized  bool
+)
+
+type deliverPayload struct {
+	DeliveryID string `bson:"deliveryId"`
+}
+
+// Initialize configures the package and registers the delivery handler
+// with the jobs package.
+func Initialize(cfg Config) error {
+	configInit.Do(func() {
+		if cfg.EndpointsCollection == "" {
+			cfg.EndpointsCollection = "webhook_endpoints"
+		}
+		if cfg.DeliveriesCollection == "" {
+			cfg.DeliveriesCollection = "webhook_deliveries"
+		}
+		if cfg.Timeout == 0 {
+			cfg.Timeout = 10 * time.Second
+		}
+		if cfg.MaxAttempts <= 0 {
+			cfg.MaxAttempts = 8
+		}
+		if cfg.HTTPClient == nil {
+			cfg.HTTPClient = SafeHTTPClient()
+		}
+		if cfg.UserAgent == "" {
+			cfg.UserAgent = "go-libs-webhooks"
+		}
+
+		collection := storage.GetCollectionRef(context.Background(), cfg.DeliveriesCollection)
+		if collection == nil {
+			configError = fmt.Errorf("failed to get collection %s", cfg.DeliveriesCollection)
+			return
+		}
+		_, configError = collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys: bson.D{{Key: "endpointId", Value: 1}, {Key: "createdAt", Value: -1}},
+		})
+		if configError != nil {
+			configError = fmt.Errorf("failed to create webhook delivery index: %w", configError)
+			return
+		}
+
+		webhooksConfig = cfg
+		jobs.Register(deliverJob, deliver)
+		isInitialized = true
+		logging.Info("Webhooks initialized successfully")
+	})
+	return configError
+}
+
+// SafeHTTPClient returns a client for delivering to untrusted URLs. It
+// refuses to connect to loopback, private, link-local and other
+// non-public addresses, checked on every connection so DNS tricks do not
+// get around it, and it does not follow redirects.
+func SafeHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not covered
+// by net.IP.IsPrivate.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// deliver is the jobs handler sending one delivery. Transient failures are
+// returned so the jobs package retries them with backoff.
+func deliver(ctx context.Context, job *jobs.Job) error {
+	var payload deliverPayload
+	if err := job.Bind(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var deliveries []Delivery
+	if err := find(ctx, webhooksConfig.DeliveriesCollection, bson.M{"_id": payload.DeliveryID}, nil, &deliveries); err != nil {
+		return fmt.Errorf("failed to load delivery %s: %w", payload.DeliveryID, err)
+	}
+	if len(deliveries) == 0 {
+		return jobs.Permanent(fmt.Errorf("delivery %s not found", payload.DeliveryID))
+	}
+	delivery := &deliveries[0]
+
+	attempt, retryable := attemptDelivery(ctx, delivery)
+
+	status := DeliveryStatusPending
+	var result error
+	switch {
+	case attempt.Error == "":
+		status = DeliveryStatusSucceeded
+	case retryable && job.Attempts < job.MaxAttempts:
+		result = errors.New(attempt.Error)
+	default:
+		status = DeliveryStatusFailed
+		result = jobs.Permanent(errors.New(attempt.Error))
+		logging.Error("Webhook delivery failed", "id", delivery.ID, "endpointId", delivery.EndpointID, "attempts", delivery.AttemptCount+1, "error", attempt.Error)
+	}
+
+	collection := storage.GetCollectionRef(ctx, webhooksConfig.DeliveriesCollection)
+	if collection == nil {
+		return fmt.Errorf("failed to get collection %s", webhooksConfig.DeliveriesCollection)
+	}
+	// The job context may be cancelled by jobs.Stop; the attempt is still
+	// recorded.
+	update := bson.M{
+		"$set":  bson.M{"status": status},
+		"$inc":  bson.M{"attemptCount": 1},
+		"$push": bson.M{"attempts": attempt},
+	}
+	if _, err := collection.UpdateOne(context.Background(), bson.M{"_id": delivery.ID}, update); err != nil {
+		logging.Error("Failed to record webhook attempt", "id", delivery.ID, "error", err)
+	}
+	return result
+}
+
+// attemptDelivery posts the delivery to its endpoint. It reports whether a
+// failed attempt is worth retrying.
+func attemptDelivery(ctx context.Context, delivery *Delivery) (Attempt, bool) {
+	attempt := Attempt{At: time.Now()}
+	fail := func(err error, retryable bool) (Attempt, bool) {
+		attempt.Error = err.Error()
+		attempt.Duration = time.Since(attempt.At)
+		return attempt, retryable
+	}
+
+	endpoint, err := findEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return fail(fmt.Errorf("failed to load endpoint: %w", err), true)
+	}
+	if endpoint == nil {
+		return fail(fmt.Errorf("endpoint %s was deleted", delivery.EndpointID), false)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhooksConfig.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fail(err, false)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhooksConfig.UserAgent)
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, sign(endpoint.Secret, timestamp, body))
+
+	resp, err := webhooksConfig.HTTPClient.Do(req)
+	if err != nil {
+		return fail(err, true)
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	attempt.StatusCode = resp.StatusCode
+	attempt.Response = string(response)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		attempt.Duration = time.Since(attempt.At)
+		return attempt, false
+	}
+	// Client errors other than timeouts and throttling will not go away on
+	// their own.
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return fail(fmt.Errorf("endpoint responded with status %d", resp.StatusCode), retryable)
+}
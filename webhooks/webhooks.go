@@ -0,0 +1,348 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delightmichael1/go-libs/jobs"
+	"github.com/delightmichael1/go-libs/logging"
+	"github.com/delightmichael1/go-libs/storage"
+	"github.com/delightmichael1/go-libs/utils"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature carries "sha256=" followed by the hex HMAC-SHA256 of
+	// the timestamp, a dot and the body, keyed with the endpoint secret.
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Endpoint is a registered receiver of webhooks.
+type Endpoint struct {
+	ID  string `bson:"_id" json:"id"`
+	URL string `bson:"url" json:"url"`
+	// Secret signs deliveries to this endpoint. It is shared with the
+	// receiver, which verifies deliveries with VerifySignature.
+	Secret string `bson:"secret" json:"secret"`
+	// Events the endpoint subscribes to; empty means all events.
+	Events    []string  `bson:"events,omitempty" json:"events,omitempty"`
+	Active    bool      `bson:"active" json:"active"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// Delivery is one event sent to one endpoint, with the history of its
+// delivery attempts.
+type Delivery struct {
+	ID           string    `bson:"_id" json:"id"`
+	EndpointID   string    `bson:"endpointId" json:"endpointId"`
+	Event        string    `bson:"event" json:"event"`
+	Body         string    `bson:"body" json:"body"`
+	Status       string    `bson:"status" json:"status"`
+	AttemptCount int       `bson:"attemptCount" json:"attemptCount"`
+	Attempts     []Attempt `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// Attempt records one HTTP request of a delivery.
+type Attempt struct {
+	At         time.Time     `bson:"at" json:"at"`
+	StatusCode int           `bson:"statusCode,omitempty" json:"statusCode,omitempty"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+	// Response holds the start of the response body.
+	Response string `bson:"response,omitempty" json:"response,omitempty"`
+}
+
+// envelope is the JSON body of every delivery.
+type envelope struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// RegisterEndpoint adds an endpoint receiving events, or every event when
+// events is empty, and generates its signing secret. endpointURL must be an
+// absolute http or https URL.
+func RegisterEndpoint(ctx context.Context, endpointURL string, events []string) (*Endpoint, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+	if err := validateEndpointURL(endpointURL); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.RandomHexKey(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate endpoint secret: %w", err)
+	}
+
+	endpoint := &Endpoint{
+		ID:        uuid.NewString(),
+		URL:       endpointURL,
+		Secret:    secret,
+		Events:    events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if _, err := storage.InsertData(ctx, webhooksConfig.EndpointsCollection, endpoint); err != nil {
+		return nil, fmt.Errorf("failed to register endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func validateEndpointURL(endpointURL string) error {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("endpoint URL must be an absolute http(s) URL, got %q", endpointURL)
+	}
+	return nil
+}
+
+// SetEndpointActive pauses or resumes deliveries to an endpoint. Events
+// dispatched while it is paused are not delivered to it.
+func SetEndpointActive(ctx context.Context, id string, active bool) error {
+	if !isInitialized {
+		return fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+
+	result, err := storage.UpdateOne(ctx, webhooksConfig.EndpointsCollection, bson.M{"_id": id}, bson.M{"active": active})
+	if err != nil {
+		return fmt.Errorf("failed to update endpoint %s: %w", id, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("endpoint %s not found", id)
+	}
+	return nil
+}
+
+// DeleteEndpoint removes an endpoint. Its pending deliveries fail.
+func DeleteEndpoint(ctx context.Context, id string) error {
+	if !isInitialized {
+		return fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+
+	result, err := storage.DeleteOne(ctx, webhooksConfig.EndpointsCollection, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete endpoint %s: %w", id, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("endpoint %s not found", id)
+	}
+	return nil
+}
+
+// ListEndpoints returns all registered endpoints.
+func ListEndpoints(ctx context.Context) ([]Endpoint, error) {
+	endpoints := []Endpoint{}
+	if err := find(ctx, webhooksConfig.EndpointsCollection, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}), &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to list endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// Dispatch queues event with payload for every active endpoint subscribed
+// to it and returns the IDs of the created deliveries.
+func Dispatch(ctx context.Context, event string, payload any) ([]string, error) {
+	if !isInitialized {
+		return nil, fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+	if event == "" {
+		return nil, fmt.Errorf("event cannot be empty")
+	}
+
+	var endpoints []Endpoint
+	if err := find(ctx, webhooksConfig.EndpointsCollection, bson.M{"active": true}, nil, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to load endpoints: %w", err)
+	}
+
+	now := time.Now()
+	var ids []string
+	for _, endpoint := range endpoints {
+		if len(endpoint.Events) > 0 && !slices.Contains(endpoint.Events, event) {
+			continue
+		}
+
+		id := uuid.NewString()
+		body, err := json.Marshal(envelope{ID: id, Event: event, CreatedAt: now, Data: payload})
+		if err != nil {
+			return ids, fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+
+		delivery := &Delivery{
+			ID:         id,
+			EndpointID: endpoint.ID,
+			Event:      event,
+			Body:       string(body),
+			Status:     DeliveryStatusPending,
+			CreatedAt:  now,
+		}
+		if _, err := storage.InsertData(ctx, webhooksConfig.DeliveriesCollection, delivery); err != nil {
+			return ids, fmt.Errorf("failed to store webhook delivery: %w", err)
+		}
+		if err := enqueueDelivery(ctx, id); err != nil {
+			if _, deleteErr := storage.DeleteOne(ctx, webhooksConfig.DeliveriesCollection, bson.M{"_id": id}); deleteErr != nil {
+				logging.Error("Failed to remove unqueued webhook delivery", "id", id, "error", deleteErr)
+			}
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Redeliver sends a finished delivery again with a fresh set of attempts,
+// e.g. after the receiver fixed an outage. Its attempt history is kept.
+func Redeliver(ctx context.Context, deliveryID string) error {
+	if !isInitialized {
+		return fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+
+	delivery, err := GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	if delivery.Status == DeliveryStatusPending {
+		return fmt.Errorf("delivery %s is still pending", deliveryID)
+	}
+	filter := bson.M{"_id": deliveryID, "status": delivery.Status}
+	result, err := storage.UpdateOne(ctx, webhooksConfig.DeliveriesCollection, filter, bson.M{"status": DeliveryStatusPending})
+	if err != nil {
+		return fmt.Errorf("failed to redeliver %s: %w", deliveryID, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("delivery %s is already being redelivered", deliveryID)
+	}
+
+	if err := enqueueDelivery(ctx, deliveryID); err != nil {
+		restore := bson.M{"_id": deliveryID, "status": DeliveryStatusPending}
+		if _, restoreErr := storage.UpdateOne(ctx, webhooksConfig.DeliveriesCollection, restore, bson.M{"status": delivery.Status}); restoreErr != nil {
+			logging.Error("Failed to restore webhook delivery status", "id", deliveryID, "error", restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func enqueueDelivery(ctx context.Context, deliveryID string) error {
+	_, err := jobs.EnqueueWith(ctx, deliverJob, deliverPayload{DeliveryID: deliveryID}, jobs.EnqueueOptions{
+		MaxAttempts: webhooksConfig.MaxAttempts,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDelivery returns a delivery with its attempts.
+func GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	var deliveries []Delivery
+	if err := find(ctx, webhooksConfig.DeliveriesCollection, bson.M{"_id": id}, nil, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to load delivery %s: %w", id, err)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("delivery %s not found", id)
+	}
+	return &deliveries[0], nil
+}
+
+// ListDeliveries returns up to limit deliveries to an endpoint, newest first.
+func ListDeliveries(ctx context.Context, endpointID string, limit int) ([]Delivery, error) {
+	opts := options.Find().SetSort(bson.M{"createdAt": -1})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	deliveries := []Delivery{}
+	if err := find(ctx, webhooksConfig.DeliveriesCollection, bson.M{"endpointId": endpointID}, opts, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// VerifySignature checks the signature headers of a received webhook.
+// Deliveries with timestamps older than tolerance are rejected to prevent
+// replays; zero disables the check.
+func VerifySignature(secret string, timestamp string, body []byte, signature string, tolerance time.Duration) bool {
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		age := time.Since(time.Unix(unix, 0))
+		if age > tolerance || age < -tolerance {
+			return false
+		}
+	}
+
+	hexSignature, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	return utils.VerifyHMAC(signingPayload(timestamp, body), hexSignature, []byte(secret))
+}
+
+func sign(secret string, timestamp string, body []byte) string {
+	return "sha256=" + utils.SignHMAC(signingPayload(timestamp, body), []byte(secret))
+}
+
+func signingPayload(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"."), body...)
+}
+
+func find(ctx context.Context, collectionName string, filter any, opts *options.FindOptions, out any) error {
+	if !isInitialized {
+		return fmt.Errorf("webhooks not initialized. Call Initialize() first")
+	}
+	collection := storage.GetCollectionRef(ctx, collectionName)
+	if collection == nil {
+		return fmt.Errorf("failed to get collection %s", collectionName)
+	}
+
+	var findOpts []*options.FindOptions
+	if opts != nil {
+		findOpts = append(findOpts, opts)
+	}
+	cursor, err := collection.Find(ctx, filter, findOpts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
+
+// findEndpoint returns the endpoint with id, or nil when it was deleted.
+func findEndpoint(ctx context.Context, id string) (*Endpoint, error) {
+	collection := storage.GetCollectionRef(ctx, webhooksConfig.EndpointsCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", webhooksConfig.EndpointsCollection)
+	}
+
+	var endpoint Endpoint
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&endpoint); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}